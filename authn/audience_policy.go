@@ -0,0 +1,89 @@
+package authn
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// AudiencePolicy decides which audiences a verifier accepts. A token passes
+// if the policy matches any of its audiences. Use it instead of a fixed
+// audience when one application serves many domains.
+type AudiencePolicy interface {
+	MatchAudience(ctx context.Context, audience string) bool
+}
+
+// AudiencePolicyFunc adapts an ordinary function to an AudiencePolicy
+type AudiencePolicyFunc func(ctx context.Context, audience string) bool
+
+// MatchAudience implements AudiencePolicy
+func (f AudiencePolicyFunc) MatchAudience(ctx context.Context, audience string) bool {
+	return f(ctx, audience)
+}
+
+// ExactAudiencePolicy accepts any of the given audiences
+func ExactAudiencePolicy(audiences ...string) AudiencePolicy {
+	allowed := map[string]bool{}
+	for _, audience := range audiences {
+		allowed[strings.ToLower(audience)] = true
+	}
+	return AudiencePolicyFunc(func(_ context.Context, audience string) bool {
+		return allowed[strings.ToLower(audience)]
+	})
+}
+
+// WildcardAudiencePolicy accepts audiences matching any of the given
+// patterns. A leading "*." matches exactly one subdomain label, so
+// "*.customers.example.com" accepts "acme.customers.example.com" but
+// neither "customers.example.com" nor "a.b.customers.example.com".
+// Patterns without a wildcard must match exactly.
+func WildcardAudiencePolicy(patterns ...string) AudiencePolicy {
+	return AudiencePolicyFunc(func(_ context.Context, audience string) bool {
+		audience = strings.ToLower(audience)
+		for _, pattern := range patterns {
+			if matchWildcard(strings.ToLower(pattern), audience) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func matchWildcard(pattern, audience string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return pattern == audience
+	}
+	suffix := pattern[1:]
+	if !strings.HasSuffix(audience, suffix) {
+		return false
+	}
+	label := audience[:len(audience)-len(suffix)]
+	return label != "" && !strings.Contains(label, ".")
+}
+
+// RequestHostAudiencePolicy accepts the host of the request being served,
+// with or without its port. The host is taken from the context, where
+// Client.Middleware stores it. Outside of the middleware, store it with
+// WithRequestHost. Tokens are rejected if the context carries no host.
+//
+// Only use this policy if the Host header of your requests is checked before,
+// e.g. by a reverse proxy routing known domains only.
+func RequestHostAudiencePolicy() AudiencePolicy {
+	return AudiencePolicyFunc(func(ctx context.Context, audience string) bool {
+		host, ok := ctx.Value(requestHostContextKey).(string)
+		if !ok || host == "" {
+			return false
+		}
+		if strings.EqualFold(host, audience) {
+			return true
+		}
+		hostname, _, err := net.SplitHostPort(host)
+		return err == nil && strings.EqualFold(hostname, audience)
+	})
+}
+
+// WithRequestHost returns a copy of ctx carrying host for
+// RequestHostAudiencePolicy
+func WithRequestHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, requestHostContextKey, host)
+}
@@ -0,0 +1,96 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudiencePolicies(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name     string
+		policy   AudiencePolicy
+		ctx      context.Context
+		audience string
+		match    bool
+	}{
+		{"exact", ExactAudiencePolicy("app.example.com", "admin.example.com"), ctx, "admin.example.com", true},
+		{"exact case", ExactAudiencePolicy("app.example.com"), ctx, "App.Example.com", true},
+		{"exact mismatch", ExactAudiencePolicy("app.example.com"), ctx, "evil.example.com", false},
+		{"wildcard", WildcardAudiencePolicy("*.customers.example.com"), ctx, "acme.customers.example.com", true},
+		{"wildcard apex", WildcardAudiencePolicy("*.customers.example.com"), ctx, "customers.example.com", false},
+		{"wildcard nested", WildcardAudiencePolicy("*.customers.example.com"), ctx, "a.acme.customers.example.com", false},
+		{"wildcard suffix only", WildcardAudiencePolicy("*.customers.example.com"), ctx, "evilcustomers.example.com", false},
+		{"wildcard exact pattern", WildcardAudiencePolicy("*.customers.example.com", "example.com"), ctx, "example.com", true},
+		{"request host", RequestHostAudiencePolicy(), WithRequestHost(ctx, "acme.example.com"), "acme.example.com", true},
+		{"request host with port", RequestHostAudiencePolicy(), WithRequestHost(ctx, "localhost:8080"), "localhost", true},
+		{"request host and port", RequestHostAudiencePolicy(), WithRequestHost(ctx, "localhost:8080"), "localhost:8080", true},
+		{"request host mismatch", RequestHostAudiencePolicy(), WithRequestHost(ctx, "acme.example.com"), "evil.example.com", false},
+		{"request host missing", RequestHostAudiencePolicy(), ctx, "acme.example.com", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.match, tc.policy.MatchAudience(tc.ctx, tc.audience))
+		})
+	}
+}
+
+func TestIDTokenVerifierAudiencePolicy(t *testing.T) {
+	client, key := newMiddlewareTestClient(t)
+	keychain := client.verifier.(*idTokenVerifier).keychain
+	verifier, err := NewIDTokenVerifier("https://authn.example.com", "", keychain,
+		WithAudiencePolicy(WildcardAudiencePolicy("*.customers.example.com")))
+	require.NoError(t, err)
+
+	sign := func(audience ...string) string {
+		claims := testClaims(time.Now())
+		claims.Audience = audience
+		return key.sign(t, claims)
+	}
+
+	_, err = verifier.GetVerifiedClaims(sign("acme.customers.example.com"))
+	assert.NoError(t, err)
+	_, err = verifier.GetVerifiedClaims(sign("app.example.com", "acme.customers.example.com"))
+	assert.NoError(t, err)
+	_, err = verifier.GetVerifiedClaims(sign("app.example.com"))
+	assert.True(t, errors.Is(err, ErrInvalidAudience))
+	_, err = verifier.GetVerifiedClaims(sign())
+	assert.True(t, errors.Is(err, ErrInvalidAudience))
+}
+
+func TestMiddlewareRequestHostAudience(t *testing.T) {
+	client, key := newMiddlewareTestClient(t)
+	keychain := client.verifier.(*idTokenVerifier).keychain
+	client.verifier, _ = NewIDTokenVerifier("https://authn.example.com", "", keychain,
+		WithAudiencePolicy(RequestHostAudiencePolicy()))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := SubjectFromContext(r.Context())
+		_, _ = w.Write([]byte(sub))
+	})
+	claims := testClaims(time.Now())
+	claims.Audience = jwt.Audience{"acme.customers.example.com"}
+	token := key.sign(t, claims)
+
+	for host, code := range map[string]int{
+		"acme.customers.example.com":      http.StatusOK,
+		"acme.customers.example.com:8443": http.StatusOK,
+		"other.customers.example.com":     http.StatusUnauthorized,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		client.Middleware()(handler).ServeHTTP(w, r)
+		assert.Equal(t, code, w.Code, host)
+	}
+}
@@ -0,0 +1,42 @@
+package authn
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuthAgeError is the cause of an ErrAuthTooOld verification error. It tells
+// how recent the authentication must be, e.g. to ask the user to log in again.
+type AuthAgeError struct {
+	AuthTime time.Time     // zero if the token has no auth_time claim
+	MaxAge   time.Duration //the maximum age that was required
+}
+
+// Error implements the error interface
+func (e *AuthAgeError) Error() string {
+	if e.AuthTime.IsZero() {
+		return fmt.Sprintf("auth_time is missing but must be within %v", e.MaxAge)
+	}
+	return fmt.Sprintf("authenticated at %v, which is more than %v ago", e.AuthTime.UTC(), e.MaxAge)
+}
+
+// CheckAuthAge returns an ErrAuthTooOld verification error unless the user
+// authenticated within maxAge according to the auth_time of claims, e.g. to
+// require a recent login on individual routes. Tokens without auth_time never
+// pass.
+func (ac *Client) CheckAuthAge(claims *Claims, maxAge time.Duration) error {
+	return checkAuthAge(claims, maxAge, ac.config.Clock.Now(), ac.config.Leeway)
+}
+
+// checkAuthAge reports whether claims were issued for an authentication at
+// most maxAge before now, tolerating leeway of clock skew
+func checkAuthAge(claims *Claims, maxAge time.Duration, now time.Time, leeway time.Duration) error {
+	if claims.AuthTime == nil {
+		return newVerificationError(ErrAuthTooOld, &AuthAgeError{MaxAge: maxAge})
+	}
+	authTime := claims.AuthTime.Time()
+	if now.After(authTime.Add(maxAge + leeway)) {
+		return newVerificationError(ErrAuthTooOld, &AuthAgeError{AuthTime: authTime, MaxAge: maxAge})
+	}
+	return nil
+}
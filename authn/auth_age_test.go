@@ -0,0 +1,67 @@
+package authn
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAuthAge(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		authTime *jwt.NumericDate
+		err      error
+	}{
+		{"recent", jwt.NewNumericDate(now.Add(-time.Minute)), nil},
+		{"within leeway", jwt.NewNumericDate(now.Add(-5*time.Minute - 30*time.Second)), nil},
+		{"too old", jwt.NewNumericDate(now.Add(-time.Hour)), &VerificationError{
+			Kind: ErrAuthTooOld,
+			Err:  &AuthAgeError{AuthTime: now.Add(-time.Hour), MaxAge: 5 * time.Minute},
+		}},
+		{"missing", nil, &VerificationError{
+			Kind: ErrAuthTooOld,
+			Err:  &AuthAgeError{MaxAge: 5 * time.Minute},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkAuthAge(&Claims{AuthTime: tc.authTime}, 5*time.Minute, now, time.Minute)
+			if tc.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.err.Error(), err.Error())
+			assert.True(t, errors.Is(err, ErrAuthTooOld))
+		})
+	}
+}
+
+func TestIDTokenVerifierMaxAuthAge(t *testing.T) {
+	client, key := newMiddlewareTestClient(t)
+	clock := newMockClock()
+
+	sign := func(authTime time.Time) string {
+		return key.sign(t, Claims{AuthTime: jwt.NewNumericDate(authTime), Claims: testClaims(clock.Now())})
+	}
+
+	verifier, err := NewIDTokenVerifier("https://authn.example.com", "app.example.com", client.verifier.(*idTokenVerifier).keychain,
+		WithClock(clock), WithMaxAuthAge(10*time.Minute))
+	require.NoError(t, err)
+
+	_, err = verifier.GetVerifiedClaims(sign(clock.Now().Add(-5 * time.Minute)))
+	assert.NoError(t, err)
+
+	_, err = verifier.GetVerifiedClaims(sign(clock.Now().Add(-time.Hour)))
+	assert.True(t, errors.Is(err, ErrAuthTooOld))
+	var aerr *AuthAgeError
+	require.True(t, errors.As(err, &aerr))
+	assert.Equal(t, 10*time.Minute, aerr.MaxAge)
+}
@@ -1,9 +1,9 @@
 package authn
 
 import (
+	"context"
 	"errors"
 	"net/http"
-	"time"
 
 	jwt "github.com/go-jose/go-jose/v3/jwt"
 )
@@ -13,13 +13,16 @@ import (
 // ErrInvalidOptions is returned by SubjectFrom if invalid options are used
 var ErrInvalidOptions = errors.New("invalid options for SubjectFrom")
 
-// Client provides JWT verification for ID tokens generated by the AuthN server. In the future it
-// will also implement the server's private APIs (aka admin actions).
+// Client provides JWT verification for ID tokens generated by the AuthN server. It also implements
+// the server's private APIs (aka admin actions) and drives its public API from the backend.
 type Client struct {
-	config   Config
-	iclient  *internalClient
-	kchain   *keychainCache
-	verifier JWTClaimsExtractor
+	config       Config
+	iclient      *internalClient
+	pclient      *publicClient
+	kchain       *keychainCache
+	verifier     JWTClaimsExtractor
+	verifierOpts []VerifierOption
+	claimsCache  *ClaimsCache
 }
 
 // NewClient returns an initialized and configured Client.
@@ -35,9 +38,35 @@ func NewClient(config Config) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.Discovery {
+		ac.iclient.enableDiscovery(config.Issuer)
+	}
+	ac.pclient, err = newPublicClient(config.Issuer, config.Origin, config.SessionCookieName)
+	if err != nil {
+		return nil, err
+	}
 
-	ac.kchain = newKeychainCache(time.Duration(config.KeychainTTL)*time.Minute, ac.iclient)
-	ac.verifier, err = NewIDTokenVerifier(config.Issuer, config.Audience, ac.kchain)
+	ac.kchain = newKeychainCache(config, ac.iclient)
+	ac.kchain.loadSnapshot()
+	if config.BackgroundKeyRefresh {
+		ac.kchain.start()
+	}
+	ac.verifierOpts = []VerifierOption{
+		WithLeeway(config.Leeway),
+		WithClock(config.Clock),
+		WithAllowedAlgorithms(config.SigningAlgorithms...),
+	}
+	if config.ClaimsCacheSize > 0 {
+		ac.claimsCache = NewClaimsCache(config.ClaimsCacheSize)
+		ac.verifierOpts = append(ac.verifierOpts, WithClaimsCache(ac.claimsCache))
+	}
+	// the WithAudience variants keep checking their explicit audiences, so the
+	// audience policy only applies to the default verifier
+	defaultOpts := ac.verifierOpts
+	if config.AudiencePolicy != nil {
+		defaultOpts = append([]VerifierOption{WithAudiencePolicy(config.AudiencePolicy)}, ac.verifierOpts...)
+	}
+	ac.verifier, err = NewIDTokenVerifier(config.Issuer, config.Audience, ac.kchain, defaultOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -45,23 +74,54 @@ func NewClient(config Config) (*Client, error) {
 	return &ac, nil
 }
 
+// Close stops the background key refresh enabled by Config.BackgroundKeyRefresh.
+// The Client keeps working afterwards, but refreshes keys on demand only.
+func (ac *Client) Close() error {
+	ac.kchain.close()
+	return nil
+}
+
+// KeyProvider returns the cached signing keys of the AuthN server, e.g. to
+// verify its tokens with a MultiIssuerVerifier.
+func (ac *Client) KeyProvider() JWKProvider {
+	return ac.kchain
+}
+
+// ClaimsCache returns the cache enabled by Config.ClaimsCacheSize, e.g. to
+// monitor its Stats, or nil if it is disabled.
+func (ac *Client) ClaimsCache() *ClaimsCache {
+	return ac.claimsCache
+}
+
 // SubjectFrom will return the subject inside the given idToken if and only if the token is a valid
 // JWT that passes all verification requirements. The returned value is the AuthN server's account
 // ID and should be used as a unique foreign key in your users data.
 //
 // If the JWT does not verify, the returned error will explain why. This is for debugging purposes.
 func (ac *Client) SubjectFrom(idToken string) (string, error) {
-	return ac.subjectFromVerifier(idToken, ac.verifier)
+	return ac.SubjectFromContext(context.Background(), idToken)
+}
+
+// SubjectFromContext works like SubjectFrom but honors the cancellation and deadline of ctx
+// while fetching signing keys.
+func (ac *Client) SubjectFromContext(ctx context.Context, idToken string) (string, error) {
+	return ac.subjectFromVerifier(ctx, idToken, ac.verifier)
 }
 
 // SubjectFromWithAudience works like SubjectFrom but allows specifying a different
 // JWT audience.
 func (ac *Client) SubjectFromWithAudience(idToken string, audience jwt.Audience) (string, error) {
-	verifier, err := newIDTokenVerifierWithAudiences(ac.config.Issuer, audience, ac.kchain)
+	return ac.SubjectFromWithAudienceContext(context.Background(), idToken, audience)
+}
+
+// SubjectFromWithAudienceContext works like SubjectFromWithAudience but honors the
+// cancellation and deadline of ctx while fetching signing keys.
+func (ac *Client) SubjectFromWithAudienceContext(ctx context.Context, idToken string, audience jwt.Audience) (string, error) {
+	verifier, err := newIDTokenVerifierWithAudiences(ac.config.Issuer, audience, ac.kchain, ac.verifierOpts...)
 	if err != nil {
 		return "", err
 	}
-	return ac.subjectFromVerifier(idToken, verifier)
+	return ac.subjectFromVerifier(ctx, idToken, verifier)
 }
 
 // ClaimsFrom will return all verified claims inside the given idToken
@@ -69,80 +129,244 @@ func (ac *Client) SubjectFromWithAudience(idToken string, audience jwt.Audience)
 // verification requirements. If the JWT does not verify, the returned
 // error will explain why. This is for debugging purposes.
 func (ac *Client) ClaimsFrom(idToken string) (*Claims, error) {
-	return ac.claimsFromVerifier(idToken, ac.verifier)
+	return ac.ClaimsFromContext(context.Background(), idToken)
+}
+
+// ClaimsFromContext works like ClaimsFrom but honors the cancellation
+// and deadline of ctx while fetching signing keys.
+func (ac *Client) ClaimsFromContext(ctx context.Context, idToken string) (*Claims, error) {
+	return ac.claimsFromVerifier(ctx, idToken, ac.verifier)
 }
 
 // ClaimsFromWithAudience works like ClaimsFrom but allows
 // specifying a different JWT audience.
 func (ac *Client) ClaimsFromWithAudience(idToken string, audience jwt.Audience) (*Claims, error) {
-	verifier, err := newIDTokenVerifierWithAudiences(ac.config.Issuer, audience, ac.kchain)
+	return ac.ClaimsFromWithAudienceContext(context.Background(), idToken, audience)
+}
+
+// ClaimsFromWithAudienceContext works like ClaimsFromWithAudience but
+// honors the cancellation and deadline of ctx while fetching signing keys.
+func (ac *Client) ClaimsFromWithAudienceContext(ctx context.Context, idToken string, audience jwt.Audience) (*Claims, error) {
+	verifier, err := newIDTokenVerifierWithAudiences(ac.config.Issuer, audience, ac.kchain, ac.verifierOpts...)
 	if err != nil {
 		return nil, err
 	}
-	return ac.claimsFromVerifier(idToken, verifier)
+	return ac.claimsFromVerifier(ctx, idToken, verifier)
 }
 
-func (ac *Client) subjectFromVerifier(idToken string, verifier JWTClaimsExtractor) (string, error) {
-	claims, err := ac.claimsFromVerifier(idToken, verifier)
+func (ac *Client) subjectFromVerifier(ctx context.Context, idToken string, verifier JWTClaimsExtractor) (string, error) {
+	claims, err := ac.claimsFromVerifier(ctx, idToken, verifier)
 	if err != nil {
 		return "", err
 	}
 	return claims.Subject, nil
 }
 
-func (ac *Client) claimsFromVerifier(idToken string, verifier JWTClaimsExtractor) (*Claims, error) {
-	claims, err := verifier.GetVerifiedClaims(idToken)
+func (ac *Client) claimsFromVerifier(ctx context.Context, idToken string, verifier JWTClaimsExtractor) (*Claims, error) {
+	claims, err := verifiedClaimsContext(ctx, verifier, idToken)
 	if err != nil {
 		return nil, err
 	}
 	return claims, nil
 }
 
+// Signup creates an account through AuthN's public API and logs it in. The
+// returned session carries the verified claims of its ID token.
+func (ac *Client) Signup(username, password string) (*Session, error) {
+	return ac.SignupContext(context.Background(), username, password)
+}
+
+// SignupContext works like Signup but honors the cancellation and deadline of ctx
+func (ac *Client) SignupContext(ctx context.Context, username, password string) (*Session, error) {
+	idToken, cookie, err := ac.pclient.Signup(ctx, username, password)
+	return ac.session(ctx, idToken, cookie, err)
+}
+
+// Login logs an account in through AuthN's public API. The returned session
+// carries the verified claims of its ID token.
+func (ac *Client) Login(username, password string) (*Session, error) {
+	return ac.LoginContext(context.Background(), username, password)
+}
+
+// LoginContext works like Login but honors the cancellation and deadline of ctx
+func (ac *Client) LoginContext(ctx context.Context, username, password string) (*Session, error) {
+	idToken, cookie, err := ac.pclient.Login(ctx, username, password)
+	return ac.session(ctx, idToken, cookie, err)
+}
+
+// RefreshSession returns a new ID token for the session with the given
+// session cookie value.
+func (ac *Client) RefreshSession(session string) (*Session, error) {
+	return ac.RefreshSessionContext(context.Background(), session)
+}
+
+// RefreshSessionContext works like RefreshSession but honors the cancellation and deadline of ctx
+func (ac *Client) RefreshSessionContext(ctx context.Context, session string) (*Session, error) {
+	idToken, cookie, err := ac.pclient.Refresh(ctx, session)
+	return ac.session(ctx, idToken, cookie, err)
+}
+
+// Logout ends the session with the given session cookie value
+func (ac *Client) Logout(session string) error {
+	return ac.LogoutContext(context.Background(), session)
+}
+
+// LogoutContext works like Logout but honors the cancellation and deadline of ctx
+func (ac *Client) LogoutContext(ctx context.Context, session string) error {
+	return ac.pclient.Logout(ctx, session)
+}
+
+// RequestPasswordReset makes AuthN send a password reset token for the account with the
+// given username to the application's password reset URL. It succeeds for unknown usernames
+// as well, so that it cannot be used to discover accounts.
+func (ac *Client) RequestPasswordReset(username string) error {
+	return ac.RequestPasswordResetContext(context.Background(), username)
+}
+
+// RequestPasswordResetContext works like RequestPasswordReset but honors the cancellation and deadline of ctx
+func (ac *Client) RequestPasswordResetContext(ctx context.Context, username string) error {
+	return ac.pclient.RequestPasswordReset(ctx, username)
+}
+
+// ResetPassword sets a new password with a token sent by RequestPasswordReset and logs the
+// account in. Rejected tokens and passwords are reported as *ErrorResponse with field errors
+// such as token: INVALID_OR_EXPIRED or password: INSECURE.
+func (ac *Client) ResetPassword(token, password string) (*Session, error) {
+	return ac.ResetPasswordContext(context.Background(), token, password)
+}
+
+// ResetPasswordContext works like ResetPassword but honors the cancellation and deadline of ctx
+func (ac *Client) ResetPasswordContext(ctx context.Context, token, password string) (*Session, error) {
+	idToken, cookie, err := ac.pclient.ResetPassword(ctx, token, password)
+	return ac.session(ctx, idToken, cookie, err)
+}
+
+// RequestPasswordlessLogin makes AuthN send a passwordless login token for the account with
+// the given username to the application's passwordless login URL. It succeeds for unknown
+// usernames as well.
+func (ac *Client) RequestPasswordlessLogin(username string) error {
+	return ac.RequestPasswordlessLoginContext(context.Background(), username)
+}
+
+// RequestPasswordlessLoginContext works like RequestPasswordlessLogin but honors the cancellation and deadline of ctx
+func (ac *Client) RequestPasswordlessLoginContext(ctx context.Context, username string) error {
+	return ac.pclient.RequestPasswordlessLogin(ctx, username)
+}
+
+// PasswordlessLogin logs an account in with a token sent by RequestPasswordlessLogin.
+// Rejected tokens are reported as *ErrorResponse with the field error token: INVALID_OR_EXPIRED.
+func (ac *Client) PasswordlessLogin(token string) (*Session, error) {
+	return ac.PasswordlessLoginContext(context.Background(), token)
+}
+
+// PasswordlessLoginContext works like PasswordlessLogin but honors the cancellation and deadline of ctx
+func (ac *Client) PasswordlessLoginContext(ctx context.Context, token string) (*Session, error) {
+	idToken, cookie, err := ac.pclient.PasswordlessLogin(ctx, token)
+	return ac.session(ctx, idToken, cookie, err)
+}
+
+// session verifies the ID token returned by the public API
+func (ac *Client) session(ctx context.Context, idToken string, cookie *http.Cookie, err error) (*Session, error) {
+	if err != nil {
+		return nil, err
+	}
+	claims, err := ac.claimsFromVerifier(ctx, idToken, ac.verifier)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{IDToken: idToken, Claims: claims, Cookie: cookie}, nil
+}
+
 // GetAccount gets the account with the associated id
 func (ac *Client) GetAccount(id string) (*Account, error) { // Should this be a string or an int?
 	return ac.iclient.GetAccount(id)
 }
 
+// GetAccountContext works like GetAccount but honors the cancellation and deadline of ctx
+func (ac *Client) GetAccountContext(ctx context.Context, id string) (*Account, error) {
+	return ac.iclient.GetAccountContext(ctx, id)
+}
+
 // Update updates the account with the associated id
 func (ac *Client) Update(id, username string) error {
 	return ac.iclient.Update(id, username)
 }
 
+// UpdateContext works like Update but honors the cancellation and deadline of ctx
+func (ac *Client) UpdateContext(ctx context.Context, id, username string) error {
+	return ac.iclient.UpdateContext(ctx, id, username)
+}
+
 // LockAccount locks the account with the associated id
 func (ac *Client) LockAccount(id string) error {
 	return ac.iclient.LockAccount(id)
 }
 
+// LockAccountContext works like LockAccount but honors the cancellation and deadline of ctx
+func (ac *Client) LockAccountContext(ctx context.Context, id string) error {
+	return ac.iclient.LockAccountContext(ctx, id)
+}
+
 // UnlockAccount unlocks the account with the associated id
 func (ac *Client) UnlockAccount(id string) error {
 	return ac.iclient.UnlockAccount(id)
 }
 
+// UnlockAccountContext works like UnlockAccount but honors the cancellation and deadline of ctx
+func (ac *Client) UnlockAccountContext(ctx context.Context, id string) error {
+	return ac.iclient.UnlockAccountContext(ctx, id)
+}
+
 // ArchiveAccount archives the account with the associated id
 func (ac *Client) ArchiveAccount(id string) error {
 	return ac.iclient.ArchiveAccount(id)
 }
 
+// ArchiveAccountContext works like ArchiveAccount but honors the cancellation and deadline of ctx
+func (ac *Client) ArchiveAccountContext(ctx context.Context, id string) error {
+	return ac.iclient.ArchiveAccountContext(ctx, id)
+}
+
 // ImportAccount imports an account with the provided information, returns the imported account id
 func (ac *Client) ImportAccount(username, password string, locked bool) (int, error) {
 	return ac.iclient.ImportAccount(username, password, locked)
 }
 
+// ImportAccountContext works like ImportAccount but honors the cancellation and deadline of ctx
+func (ac *Client) ImportAccountContext(ctx context.Context, username, password string, locked bool) (int, error) {
+	return ac.iclient.ImportAccountContext(ctx, username, password, locked)
+}
+
 // ExpirePassword expires the password of the account with the associated id
 func (ac *Client) ExpirePassword(id string) error {
 	return ac.iclient.ExpirePassword(id)
 }
 
+// ExpirePasswordContext works like ExpirePassword but honors the cancellation and deadline of ctx
+func (ac *Client) ExpirePasswordContext(ctx context.Context, id string) error {
+	return ac.iclient.ExpirePasswordContext(ctx, id)
+}
+
 // ServiceStats gets the http response object from calling the service stats endpoint
 func (ac *Client) ServiceStats() (*http.Response, error) {
 	return ac.iclient.ServiceStats()
 }
 
+// ServiceStatsContext works like ServiceStats but honors the cancellation and deadline of ctx
+func (ac *Client) ServiceStatsContext(ctx context.Context) (*http.Response, error) {
+	return ac.iclient.ServiceStatsContext(ctx)
+}
+
 // ServerStats gets the http response object from calling the server stats endpoint
 func (ac *Client) ServerStats() (*http.Response, error) {
 	return ac.iclient.ServerStats()
 }
 
+// ServerStatsContext works like ServerStats but honors the cancellation and deadline of ctx
+func (ac *Client) ServerStatsContext(ctx context.Context) (*http.Response, error) {
+	return ac.iclient.ServerStatsContext(ctx)
+}
+
 // DefaultClient can be initialized by Configure and used by SubjectFrom.
 var DefaultClient *Client
 
@@ -0,0 +1,140 @@
+// Package authntest provides utilities for testing code which verifies ID
+// tokens of Keratin AuthN. It mints AuthN-shaped tokens with a generated key
+// pair and provides the matching keys to authn's verifiers.
+package authntest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/keratin/authn-go/authn"
+)
+
+// DefaultKeyID is the key ID of the keys generated by NewIssuer
+const DefaultKeyID = "authntest"
+
+// Issuer mints ID tokens like an AuthN server with the given issuer URL and
+// audience would. Each Issuer has its own RSA key pair.
+type Issuer struct {
+	URL      string
+	Audience string
+
+	key   jose.JSONWebKey
+	other jose.JSONWebKey //a key unrelated to the issuer, for broken signatures
+}
+
+// NewIssuer creates an Issuer with a freshly generated RSA key pair
+func NewIssuer(issuer, audience string) (*Issuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Issuer{
+		URL:      issuer,
+		Audience: audience,
+		key:      jose.JSONWebKey{Key: key, KeyID: DefaultKeyID, Algorithm: string(jose.RS256), Use: "sig"},
+		other:    jose.JSONWebKey{Key: other, KeyID: DefaultKeyID, Algorithm: string(jose.RS256), Use: "sig"},
+	}, nil
+}
+
+// JWKS returns the public keys of the issuer as AuthN serves them at /jwks
+func (i *Issuer) JWKS() jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{Keys: []jose.JSONWebKey{i.key.Public()}}
+}
+
+// KeyProvider returns a JWKProvider with the public keys of the issuer, to be
+// used with authn.NewIDTokenVerifier
+func (i *Issuer) KeyProvider() authn.JWKProvider {
+	provider, err := authn.NewStaticJWKProvider(i.JWKS())
+	if err != nil {
+		// the keys of an Issuer always have a public part
+		panic(err)
+	}
+	return provider
+}
+
+// JWKSHandler returns an http.Handler serving the public keys of the issuer
+func (i *Issuer) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(i.JWKS())
+	})
+}
+
+// Verifier returns a verifier accepting the tokens of the issuer
+func (i *Issuer) Verifier(opts ...authn.VerifierOption) (authn.JWTClaimsExtractor, error) {
+	return authn.NewIDTokenVerifier(i.URL, i.Audience, i.KeyProvider(), opts...)
+}
+
+// Token mints a signed ID token. Without options, the token is valid for an
+// hour and issued for account 1 who just logged in.
+func (i *Issuer) Token(opts ...TokenOption) (string, error) {
+	now := time.Now()
+	t := &token{
+		key: i.key,
+		claims: map[string]interface{}{
+			"iss":       i.URL,
+			"aud":       i.Audience,
+			"sub":       "1",
+			"iat":       jwt.NewNumericDate(now),
+			"exp":       jwt.NewNumericDate(now.Add(time.Hour)),
+			"auth_time": jwt.NewNumericDate(now),
+			"sid":       "session",
+		},
+		other: i.other,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t.sign()
+}
+
+// MustToken works like Token but panics on errors
+func (i *Issuer) MustToken(opts ...TokenOption) string {
+	token, err := i.Token(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+// token is an ID token being minted
+type token struct {
+	key      jose.JSONWebKey
+	other    jose.JSONWebKey
+	claims   map[string]interface{}
+	unsigned bool
+}
+
+func (t *token) sign() (string, error) {
+	if t.unsigned {
+		payload, err := json.Marshal(t.claims)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join([]string{
+			base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)),
+			base64.RawURLEncoding.EncodeToString(payload),
+			"",
+		}, "."), nil
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: t.key}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+	return jwt.Signed(signer).Claims(t.claims).CompactSerialize()
+}
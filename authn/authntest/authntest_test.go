@@ -0,0 +1,89 @@
+package authntest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keratin/authn-go/authn"
+	"github.com/keratin/authn-go/authn/authntest"
+)
+
+func TestIssuer(t *testing.T) {
+	issuer, err := authntest.NewIssuer("https://authn.example.com", "app.example.com")
+	require.NoError(t, err)
+	verifier, err := issuer.Verifier()
+	require.NoError(t, err)
+
+	t.Run("defaults", func(t *testing.T) {
+		claims, err := verifier.GetVerifiedClaims(issuer.MustToken())
+		require.NoError(t, err)
+		assert.Equal(t, "1", claims.Subject)
+		assert.Equal(t, "session", claims.SessionID)
+		assert.NotNil(t, claims.AuthTime)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+		token := issuer.MustToken(
+			authntest.WithSubject("42"),
+			authntest.WithAudience("app.example.com", "admin.example.com"),
+			authntest.WithAuthTime(authTime),
+			authntest.WithSessionID("abc"),
+			authntest.WithExpiry(time.Now().Add(time.Minute)),
+		)
+		claims, err := verifier.GetVerifiedClaims(token)
+		require.NoError(t, err)
+		assert.Equal(t, "42", claims.Subject)
+		assert.Equal(t, "abc", claims.SessionID)
+		assert.Equal(t, []string{"app.example.com", "admin.example.com"}, []string(claims.Audience))
+		assert.True(t, authTime.Equal(claims.AuthTime.Time()))
+	})
+
+	testCases := []struct {
+		name string
+		opts []authntest.TokenOption
+		kind error
+	}{
+		{"expired", []authntest.TokenOption{authntest.Expired()}, authn.ErrTokenExpired},
+		{"not yet valid", []authntest.TokenOption{authntest.NotYetValid()}, authn.ErrTokenNotYetValid},
+		{"foreign issuer", []authntest.TokenOption{authntest.WithIssuer("https://evil.example.com")}, authn.ErrInvalidIssuer},
+		{"foreign audience", []authntest.TokenOption{authntest.WithAudience("evil.example.com")}, authn.ErrInvalidAudience},
+		{"without expiry", []authntest.TokenOption{authntest.WithClaim("exp", nil)}, nil},
+		{"unknown key", []authntest.TokenOption{authntest.WithKeyID("unknown")}, authn.ErrUnknownKey},
+		{"invalid signature", []authntest.TokenOption{authntest.WithInvalidSignature()}, authn.ErrInvalidSignature},
+		{"unsigned", []authntest.TokenOption{authntest.Unsigned()}, authn.ErrDisallowedAlgorithm},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := verifier.GetVerifiedClaims(issuer.MustToken(tc.opts...))
+			if tc.kind == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tc.kind), "expected %v, got %v", tc.kind, err)
+		})
+	}
+}
+
+func TestIssuerJWKSHandler(t *testing.T) {
+	issuer, err := authntest.NewIssuer("https://authn.example.com", "app.example.com")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	issuer.JWKSHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jwks", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	jwks := jose.JSONWebKeySet{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, authntest.DefaultKeyID, jwks.Keys[0].KeyID)
+	assert.True(t, jwks.Keys[0].IsPublic())
+}
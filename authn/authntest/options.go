@@ -0,0 +1,103 @@
+package authntest
+
+import (
+	"time"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+)
+
+// TokenOption customizes a token minted by Issuer.Token
+type TokenOption func(*token)
+
+// WithSubject sets the sub claim, i.e. the AuthN account ID
+func WithSubject(subject string) TokenOption {
+	return WithClaim("sub", subject)
+}
+
+// WithAudience sets the aud claim. Several audiences are encoded as an array.
+func WithAudience(audiences ...string) TokenOption {
+	if len(audiences) == 1 {
+		return WithClaim("aud", audiences[0])
+	}
+	return WithClaim("aud", audiences)
+}
+
+// WithIssuer sets the iss claim, e.g. to mint a token of a foreign issuer
+func WithIssuer(issuer string) TokenOption {
+	return WithClaim("iss", issuer)
+}
+
+// WithExpiry sets the exp claim
+func WithExpiry(expiry time.Time) TokenOption {
+	return WithClaim("exp", jwt.NewNumericDate(expiry))
+}
+
+// WithIssuedAt sets the iat claim
+func WithIssuedAt(issuedAt time.Time) TokenOption {
+	return WithClaim("iat", jwt.NewNumericDate(issuedAt))
+}
+
+// WithAuthTime sets the auth_time claim, i.e. when the user logged in
+func WithAuthTime(authTime time.Time) TokenOption {
+	return WithClaim("auth_time", jwt.NewNumericDate(authTime))
+}
+
+// WithSessionID sets the sid claim
+func WithSessionID(sessionID string) TokenOption {
+	return WithClaim("sid", sessionID)
+}
+
+// WithClaim sets any claim. A nil value removes the claim from the token.
+func WithClaim(name string, value interface{}) TokenOption {
+	return func(t *token) {
+		if value == nil {
+			delete(t.claims, name)
+			return
+		}
+		t.claims[name] = value
+	}
+}
+
+// WithKeyID sets the kid header, e.g. to a key ID the issuer does not know
+func WithKeyID(keyID string) TokenOption {
+	return func(t *token) {
+		t.key.KeyID = keyID
+	}
+}
+
+// Expired mints a token which expired an hour ago
+func Expired() TokenOption {
+	return func(t *token) {
+		now := time.Now()
+		t.claims["iat"] = jwt.NewNumericDate(now.Add(-2 * time.Hour))
+		t.claims["auth_time"] = jwt.NewNumericDate(now.Add(-2 * time.Hour))
+		t.claims["exp"] = jwt.NewNumericDate(now.Add(-time.Hour))
+	}
+}
+
+// NotYetValid mints a token which is only issued in an hour
+func NotYetValid() TokenOption {
+	return func(t *token) {
+		now := time.Now()
+		t.claims["iat"] = jwt.NewNumericDate(now.Add(time.Hour))
+		t.claims["nbf"] = jwt.NewNumericDate(now.Add(time.Hour))
+		t.claims["exp"] = jwt.NewNumericDate(now.Add(2 * time.Hour))
+	}
+}
+
+// WithInvalidSignature signs the token with a key which is not the
+// issuer's, under the issuer's key ID
+func WithInvalidSignature() TokenOption {
+	return func(t *token) {
+		keyID := t.key.KeyID
+		t.key = t.other
+		t.key.KeyID = keyID
+	}
+}
+
+// Unsigned mints a token with the "none" algorithm and no signature
+func Unsigned() TokenOption {
+	return func(t *token) {
+		t.unsigned = true
+	}
+}
@@ -0,0 +1,220 @@
+package authntest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/keratin/authn-go/authn"
+)
+
+// minPasswordLength is the length below which the fake considers passwords
+// INSECURE, standing in for AuthN's password strength estimation
+const minPasswordLength = 8
+
+// isPublic reports whether the route is one of the public API
+func isPublic(method, path string) bool {
+	switch {
+	case method == http.MethodPost && path == "accounts",
+		method == http.MethodPost && path == "session",
+		method == http.MethodGet && path == "session/refresh",
+		method == http.MethodDelete && path == "session",
+		method == http.MethodGet && path == "password/reset",
+		method == http.MethodPost && path == "password",
+		method == http.MethodGet && path == "passwordless/token",
+		method == http.MethodPost && path == "passwordless/login":
+		return true
+	}
+	return false
+}
+
+func (s *Server) servePublic(w http.ResponseWriter, r *http.Request, path string) {
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil || origin.Host != s.Issuer.Audience {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case path == "accounts":
+		s.signup(w, r)
+	case path == "session" && r.Method == http.MethodPost:
+		s.login(w, r)
+	case path == "session/refresh":
+		sess := s.currentSession(r)
+		if sess == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.writeIDToken(w, sess)
+	case path == "session":
+		if cookie, err := r.Cookie(authn.DefaultSessionCookieName); err == nil {
+			delete(s.sessions, cookie.Value)
+		}
+		http.SetCookie(w, &http.Cookie{Name: authn.DefaultSessionCookieName, Path: "/", MaxAge: -1, HttpOnly: true})
+		w.WriteHeader(http.StatusOK)
+	case path == "password/reset":
+		// unknown usernames succeed as well, so accounts cannot be discovered
+		if acc := s.byUsername(r.URL.Query().Get("username")); acc != nil && !acc.Deleted {
+			acc.resetToken = randomToken()
+		}
+		w.WriteHeader(http.StatusOK)
+	case path == "password":
+		s.resetPassword(w, r)
+	case path == "passwordless/token":
+		if acc := s.byUsername(r.URL.Query().Get("username")); acc != nil && !acc.Deleted {
+			acc.passwordlessToken = randomToken()
+		}
+		w.WriteHeader(http.StatusOK)
+	case path == "passwordless/login":
+		acc := s.byToken(r.PostFormValue("token"), func(acc *account) *string { return &acc.passwordlessToken })
+		switch {
+		case acc == nil:
+			writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "token", Message: "INVALID_OR_EXPIRED"})
+		case acc.Locked:
+			writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "account", Message: "LOCKED"})
+		default:
+			acc.passwordlessToken = ""
+			s.startSession(w, acc)
+		}
+	}
+}
+
+func (s *Server) resetPassword(w http.ResponseWriter, r *http.Request) {
+	acc := s.byToken(r.PostFormValue("token"), func(acc *account) *string { return &acc.resetToken })
+	if acc == nil {
+		writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "token", Message: "INVALID_OR_EXPIRED"})
+		return
+	}
+	if acc.Locked {
+		writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "account", Message: "LOCKED"})
+		return
+	}
+	password := r.PostFormValue("password")
+	if password == "" {
+		writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "password", Message: "MISSING"})
+		return
+	}
+	if len(password) < minPasswordLength {
+		writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "password", Message: "INSECURE"})
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	acc.password = password
+	acc.PasswordChangedAt = &now
+	acc.passwordExpired = false
+	acc.resetToken = ""
+	s.startSession(w, acc)
+}
+
+// byToken returns the account whose token selected by field is token, if any.
+// It must be called with s.mu held.
+func (s *Server) byToken(token string, field func(*account) *string) *account {
+	if token == "" {
+		return nil
+	}
+	for _, acc := range s.accounts {
+		if *field(acc) == token && !acc.Deleted {
+			return acc
+		}
+	}
+	return nil
+}
+
+func (s *Server) signup(w http.ResponseWriter, r *http.Request) {
+	username := r.PostFormValue("username")
+	password := r.PostFormValue("password")
+
+	var errs []authn.FieldError
+	if username == "" {
+		errs = append(errs, authn.FieldError{Field: "username", Message: "MISSING"})
+	} else if s.byUsername(username) != nil {
+		errs = append(errs, authn.FieldError{Field: "username", Message: "TAKEN"})
+	}
+	if password == "" {
+		errs = append(errs, authn.FieldError{Field: "password", Message: "MISSING"})
+	} else if len(password) < minPasswordLength {
+		errs = append(errs, authn.FieldError{Field: "password", Message: "INSECURE"})
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusUnprocessableEntity, errs...)
+		return
+	}
+
+	s.startSession(w, s.add(username, password, false))
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	acc := s.byUsername(r.PostFormValue("username"))
+	switch {
+	case acc == nil || acc.Deleted || acc.password != r.PostFormValue("password"):
+		writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "credentials", Message: "FAILED"})
+	case acc.Locked:
+		writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "account", Message: "LOCKED"})
+	case acc.passwordExpired:
+		writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "credentials", Message: "EXPIRED"})
+	default:
+		s.startSession(w, acc)
+	}
+}
+
+// startSession logs acc in, setting the session cookie. It must be called
+// with s.mu held.
+func (s *Server) startSession(w http.ResponseWriter, acc *account) {
+	now := time.Now().UTC().Truncate(time.Second)
+	acc.LastLoginAt = &now
+
+	value := randomToken()
+	sess := &session{accountID: acc.ID, id: randomToken(), authTime: now}
+	s.sessions[value] = sess
+	http.SetCookie(w, &http.Cookie{Name: authn.DefaultSessionCookieName, Value: value, Path: "/", HttpOnly: true})
+	s.writeIDToken(w, sess)
+}
+
+// currentSession returns the session of the request's cookie, if any. It
+// must be called with s.mu held.
+func (s *Server) currentSession(r *http.Request) *session {
+	cookie, err := r.Cookie(authn.DefaultSessionCookieName)
+	if err != nil {
+		return nil
+	}
+	return s.sessions[cookie.Value]
+}
+
+// revokeSessions ends all sessions of the account with the given ID. It must
+// be called with s.mu held.
+func (s *Server) revokeSessions(id int) {
+	for value, sess := range s.sessions {
+		if sess.accountID == id {
+			delete(s.sessions, value)
+		}
+	}
+}
+
+func (s *Server) writeIDToken(w http.ResponseWriter, sess *session) {
+	idToken, err := s.Issuer.Token(
+		WithSubject(strconv.Itoa(sess.accountID)),
+		WithSessionID(sess.id),
+		WithAuthTime(sess.authTime),
+	)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"result": map[string]string{"id_token": idToken}})
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
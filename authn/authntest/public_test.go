@@ -0,0 +1,134 @@
+package authntest_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keratin/authn-go/authn"
+	"github.com/keratin/authn-go/authn/authntest"
+)
+
+func TestServerPublicAPI(t *testing.T) {
+	server := authntest.NewServer("app.example.com")
+	defer server.Close()
+
+	client, err := authn.NewClient(server.Config())
+	require.NoError(t, err)
+
+	fieldError := func(err error, field string) string {
+		var errResp *authn.ErrorResponse
+		require.True(t, errors.As(err, &errResp), "expected an ErrorResponse, got %v", err)
+		msg, _ := errResp.Field(field)
+		return msg
+	}
+
+	t.Run("signup", func(t *testing.T) {
+		session, err := client.Signup("alice", "correct horse")
+		require.NoError(t, err)
+		require.NotNil(t, session.Cookie)
+
+		account, ok := server.AccountByUsername("alice")
+		require.True(t, ok)
+		assert.Equal(t, strconv.Itoa(account.ID), session.Claims.Subject)
+		assert.NotNil(t, account.LastLoginAt)
+		assert.True(t, server.LoggedIn(account.ID))
+
+		_, err = client.Signup("alice", "short")
+		assert.Equal(t, "TAKEN", fieldError(err, "username"))
+		assert.Equal(t, "INSECURE", fieldError(err, "password"))
+	})
+
+	t.Run("session", func(t *testing.T) {
+		id := server.AddAccount("bob", "correct horse")
+
+		_, err := client.Login("bob", "wrong")
+		assert.Equal(t, "FAILED", fieldError(err, "credentials"))
+
+		session, err := client.Login("bob", "correct horse")
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(id), session.Claims.Subject)
+
+		refreshed, err := client.RefreshSession(session.Cookie.Value)
+		require.NoError(t, err)
+		assert.Equal(t, session.Claims.SessionID, refreshed.Claims.SessionID)
+		assert.Equal(t, session.Claims.AuthTime, refreshed.Claims.AuthTime)
+
+		require.NoError(t, client.Logout(session.Cookie.Value))
+		assert.False(t, server.LoggedIn(id))
+		_, err = client.RefreshSession(session.Cookie.Value)
+		assert.Error(t, err)
+	})
+
+	t.Run("locked and expired", func(t *testing.T) {
+		id := server.AddAccount("carol", "correct horse")
+		session, err := client.Login("carol", "correct horse")
+		require.NoError(t, err)
+
+		require.NoError(t, client.ExpirePassword(strconv.Itoa(id)))
+		_, err = client.RefreshSession(session.Cookie.Value)
+		assert.Error(t, err)
+		_, err = client.Login("carol", "correct horse")
+		assert.Equal(t, "EXPIRED", fieldError(err, "credentials"))
+
+		require.NoError(t, client.LockAccount(strconv.Itoa(id)))
+		_, err = client.Login("carol", "correct horse")
+		assert.Equal(t, "LOCKED", fieldError(err, "account"))
+	})
+
+	t.Run("origin", func(t *testing.T) {
+		config := server.Config()
+		config.Origin = "https://evil.example.com"
+		client, err := authn.NewClient(config)
+		require.NoError(t, err)
+
+		_, err = client.Login("alice", "correct horse")
+		assert.EqualError(t, err, "received 403 from "+server.URL+"/session")
+	})
+
+	t.Run("password reset", func(t *testing.T) {
+		id := server.AddAccount("dave", "correct horse")
+		require.NoError(t, client.ExpirePassword(strconv.Itoa(id)))
+
+		require.NoError(t, client.RequestPasswordReset("nobody"))
+		require.NoError(t, client.RequestPasswordReset("dave"))
+		token, ok := server.PasswordResetToken(id)
+		require.True(t, ok)
+
+		_, err := client.ResetPassword(token, "short")
+		assert.Equal(t, "INSECURE", fieldError(err, "password"))
+		_, err = client.ResetPassword("forged", "battery staple")
+		assert.Equal(t, "INVALID_OR_EXPIRED", fieldError(err, "token"))
+
+		session, err := client.ResetPassword(token, "battery staple")
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(id), session.Claims.Subject)
+		assert.False(t, server.PasswordExpired(id))
+
+		// tokens are single-use
+		_, err = client.ResetPassword(token, "battery staple")
+		assert.Equal(t, "INVALID_OR_EXPIRED", fieldError(err, "token"))
+
+		_, err = client.Login("dave", "battery staple")
+		assert.NoError(t, err)
+	})
+
+	t.Run("passwordless", func(t *testing.T) {
+		id := server.AddAccount("erin", "correct horse")
+
+		require.NoError(t, client.RequestPasswordlessLogin("erin"))
+		token, ok := server.PasswordlessToken(id)
+		require.True(t, ok)
+
+		session, err := client.PasswordlessLogin(token)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(id), session.Claims.Subject)
+		assert.True(t, server.LoggedIn(id))
+
+		_, err = client.PasswordlessLogin(token)
+		assert.Equal(t, "INVALID_OR_EXPIRED", fieldError(err, "token"))
+	})
+}
@@ -0,0 +1,357 @@
+package authntest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keratin/authn-go/authn"
+)
+
+// Server is an in-process fake of an AuthN server for integration tests. It
+// serves the private admin endpoints from an in-memory account store as well
+// as /jwks and the configuration document. Its Issuer mints tokens which the
+// server's keys verify.
+type Server struct {
+	*httptest.Server
+	Issuer *Issuer
+
+	// Credentials required by the private endpoints
+	Username string
+	Password string
+
+	mu       sync.Mutex
+	accounts map[int]*account
+	nextID   int
+	sessions map[string]*session //by session cookie value
+}
+
+// session is a login session of an account
+type session struct {
+	accountID int
+	id        string    //the sid claim of its ID tokens
+	authTime  time.Time //when the account logged in
+}
+
+// account is an account in the store of a Server
+type account struct {
+	authn.Account
+	password          string
+	passwordExpired   bool
+	resetToken        string //the last password reset token sent, if any
+	passwordlessToken string //the last passwordless login token sent, if any
+}
+
+// NewServer starts a Server issuing tokens for audience. Its private endpoints
+// accept the credentials "username" and "password", and its public endpoints
+// requests from the origin of audience. The caller should call Close when
+// finished, to shut it down.
+func NewServer(audience string) *Server {
+	s := &Server{
+		Username: "username",
+		Password: "password",
+		accounts: map[int]*account{},
+		nextID:   1,
+		sessions: map[string]*session{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	issuer, err := NewIssuer(s.URL, audience)
+	if err != nil {
+		s.Close()
+		panic("authntest: generating keys: " + err.Error())
+	}
+	s.Issuer = issuer
+	return s
+}
+
+// Config returns the configuration of an authn.Client using s
+func (s *Server) Config() authn.Config {
+	return authn.Config{
+		Issuer:   s.URL,
+		Audience: s.Issuer.Audience,
+		Username: s.Username,
+		Password: s.Password,
+	}
+}
+
+// AddAccount stores a new account and returns its ID
+func (s *Server) AddAccount(username, password string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(username, password, false).ID
+}
+
+// Account returns the stored account with the given ID
+func (s *Server) Account(id int) (authn.Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[id]
+	if !ok {
+		return authn.Account{}, false
+	}
+	return acc.Account, true
+}
+
+// AccountByUsername returns the stored account with the given username
+func (s *Server) AccountByUsername(username string) (authn.Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc := s.byUsername(username)
+	if acc == nil {
+		return authn.Account{}, false
+	}
+	return acc.Account, true
+}
+
+// Accounts returns all stored accounts ordered by ID
+func (s *Server) Accounts() []authn.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	accounts := make([]authn.Account, 0, len(s.accounts))
+	for _, acc := range s.accounts {
+		accounts = append(accounts, acc.Account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts
+}
+
+// LinkOAuthAccount links an identity of an OAuth provider to the account with
+// the given ID. It reports whether the account exists.
+func (s *Server) LinkOAuthAccount(id int, oauthAccount authn.OAuthAccount) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[id]
+	if ok {
+		acc.OAuthAccounts = append(acc.OAuthAccounts, oauthAccount)
+	}
+	return ok
+}
+
+// LoggedIn reports whether the account with the given ID has any session
+func (s *Server) LoggedIn(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.sessions {
+		if sess.accountID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordResetToken returns the password reset token which AuthN would have
+// sent to the application for the account with the given ID, if any
+func (s *Server) PasswordResetToken(id int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[id]
+	if !ok || acc.resetToken == "" {
+		return "", false
+	}
+	return acc.resetToken, true
+}
+
+// PasswordlessToken returns the passwordless login token which AuthN would
+// have sent to the application for the account with the given ID, if any
+func (s *Server) PasswordlessToken(id int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[id]
+	if !ok || acc.passwordlessToken == "" {
+		return "", false
+	}
+	return acc.passwordlessToken, true
+}
+
+// PasswordExpired reports whether the password of the account with the
+// given ID was expired
+func (s *Server) PasswordExpired(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[id]
+	return ok && acc.passwordExpired
+}
+
+// add stores a new account. It must be called with s.mu held.
+func (s *Server) add(username, password string, locked bool) *account {
+	now := time.Now().UTC().Truncate(time.Second)
+	acc := &account{
+		Account: authn.Account{
+			ID:                s.nextID,
+			Username:          username,
+			OAuthAccounts:     []authn.OAuthAccount{},
+			PasswordChangedAt: &now,
+			Locked:            locked,
+		},
+		password: password,
+	}
+	s.accounts[acc.ID] = acc
+	s.nextID++
+	return acc
+}
+
+// byUsername returns the account with the given username, if any. It must
+// be called with s.mu held.
+func (s *Server) byUsername(username string) *account {
+	for _, acc := range s.accounts {
+		if acc.Username == username {
+			return acc
+		}
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && path == "jwks":
+		s.Issuer.JWKSHandler().ServeHTTP(w, r)
+		return
+	case r.Method == http.MethodGet && (path == "configuration" || path == ".well-known/openid-configuration"):
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":   s.URL,
+			"jwks_uri": s.URL + "/jwks",
+		})
+		return
+	case isPublic(r.Method, path):
+		s.servePublic(w, r, path)
+		return
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok || username != s.Username || password != s.Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments := strings.Split(path, "/")
+	switch {
+	case r.Method == http.MethodGet && path == "stats":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"actives": map[string]interface{}{}})
+	case r.Method == http.MethodGet && path == "metrics":
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && path == "accounts/import":
+		s.importAccount(w, r)
+	case len(segments) >= 2 && segments[0] == "accounts":
+		acc := s.account(segments[1])
+		if acc == nil {
+			writeErrors(w, http.StatusNotFound, authn.FieldError{Field: "account", Message: "NOT_FOUND"})
+			return
+		}
+		s.serveAccount(w, r, acc, strings.Join(segments[2:], "/"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// account returns the account with the given ID, if any. It must be called
+// with s.mu held.
+func (s *Server) account(id string) *account {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil
+	}
+	return s.accounts[n]
+}
+
+func (s *Server) serveAccount(w http.ResponseWriter, r *http.Request, acc *account, action string) {
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"result": accountJSON(acc.Account)})
+	case r.Method == http.MethodPatch && action == "":
+		username := r.PostFormValue("username")
+		if username == "" {
+			writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "username", Message: "MISSING"})
+			return
+		}
+		if other := s.byUsername(username); other != nil && other != acc {
+			writeErrors(w, http.StatusUnprocessableEntity, authn.FieldError{Field: "username", Message: "TAKEN"})
+			return
+		}
+		acc.Username = username
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPatch && action == "lock":
+		acc.Locked = true
+		s.revokeSessions(acc.ID)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPatch && action == "unlock":
+		acc.Locked = false
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPatch && action == "expire_password":
+		acc.passwordExpired = true
+		s.revokeSessions(acc.ID)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodDelete && action == "":
+		acc.Deleted = true
+		acc.Username = ""
+		acc.password = ""
+		s.revokeSessions(acc.ID)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// accountJSON returns acc the way AuthN serializes it, with unset times as
+// empty strings
+func accountJSON(acc authn.Account) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                  acc.ID,
+		"username":            acc.Username,
+		"oauth_accounts":      acc.OAuthAccounts,
+		"last_login_at":       timeJSON(acc.LastLoginAt),
+		"password_changed_at": timeJSON(acc.PasswordChangedAt),
+		"locked":              acc.Locked,
+		"deleted":             acc.Deleted,
+	}
+}
+
+func timeJSON(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (s *Server) importAccount(w http.ResponseWriter, r *http.Request) {
+	username := r.PostFormValue("username")
+	password := r.PostFormValue("password")
+
+	var errs []authn.FieldError
+	if username == "" {
+		errs = append(errs, authn.FieldError{Field: "username", Message: "MISSING"})
+	} else if s.byUsername(username) != nil {
+		errs = append(errs, authn.FieldError{Field: "username", Message: "TAKEN"})
+	}
+	if password == "" {
+		errs = append(errs, authn.FieldError{Field: "password", Message: "MISSING"})
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusUnprocessableEntity, errs...)
+		return
+	}
+
+	locked, _ := strconv.ParseBool(r.PostFormValue("locked"))
+	acc := s.add(username, password, locked)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"result": map[string]int{"id": acc.ID}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeErrors(w http.ResponseWriter, status int, errs ...authn.FieldError) {
+	writeJSON(w, status, map[string]interface{}{"errors": errs})
+}
@@ -0,0 +1,104 @@
+package authntest_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/keratin/authn-go/authn"
+	"github.com/keratin/authn-go/authn/authntest"
+)
+
+func TestServer(t *testing.T) {
+	server := authntest.NewServer("app.example.com")
+	defer server.Close()
+
+	client, err := authn.NewClient(server.Config())
+	require.NoError(t, err)
+	defer client.Close()
+
+	t.Run("tokens", func(t *testing.T) {
+		sub, err := client.SubjectFrom(server.Issuer.MustToken(authntest.WithSubject("7")))
+		require.NoError(t, err)
+		assert.Equal(t, "7", sub)
+	})
+
+	t.Run("accounts", func(t *testing.T) {
+		id := server.AddAccount("alice", "secret")
+
+		require.True(t, server.LinkOAuthAccount(id, authn.OAuthAccount{Provider: "google", ProviderAccountID: "1234"}))
+		account, err := client.GetAccount(strconv.Itoa(id))
+		require.NoError(t, err)
+		assert.Equal(t, id, account.ID)
+		assert.Equal(t, "alice", account.Username)
+		assert.Equal(t, []authn.OAuthAccount{{Provider: "google", ProviderAccountID: "1234"}}, account.OAuthAccounts)
+		assert.Nil(t, account.LastLoginAt)
+		assert.NotNil(t, account.PasswordChangedAt)
+
+		importedID, err := client.ImportAccount("bob", "secret", true)
+		require.NoError(t, err)
+		imported, ok := server.AccountByUsername("bob")
+		require.True(t, ok)
+		assert.Equal(t, importedID, imported.ID)
+		assert.True(t, imported.Locked)
+
+		require.NoError(t, client.UnlockAccount(strconv.Itoa(importedID)))
+		require.NoError(t, client.Update(strconv.Itoa(importedID), "robert"))
+		require.NoError(t, client.ExpirePassword(strconv.Itoa(importedID)))
+		imported, _ = server.Account(importedID)
+		assert.Equal(t, "robert", imported.Username)
+		assert.False(t, imported.Locked)
+		assert.True(t, server.PasswordExpired(importedID))
+
+		require.NoError(t, client.LockAccount(strconv.Itoa(id)))
+		require.NoError(t, client.ArchiveAccount(strconv.Itoa(id)))
+		archived, _ := server.Account(id)
+		assert.True(t, archived.Locked)
+		assert.True(t, archived.Deleted)
+
+		assert.Len(t, server.Accounts(), 2)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		server.AddAccount("carol", "secret")
+
+		_, err := client.ImportAccount("carol", "", false)
+		var errResp *authn.ErrorResponse
+		require.True(t, errors.As(err, &errResp))
+		assert.Equal(t, 422, errResp.StatusCode)
+		assert.Equal(t, []authn.FieldError{
+			{Field: "username", Message: "TAKEN"},
+			{Field: "password", Message: "MISSING"},
+		}, errResp.Errors)
+
+		_, err = client.GetAccount("9999")
+		require.True(t, errors.As(err, &errResp))
+		assert.Equal(t, 404, errResp.StatusCode)
+		msg, ok := errResp.Field("account")
+		assert.True(t, ok)
+		assert.Equal(t, "NOT_FOUND", msg)
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		config := server.Config()
+		config.Password = "wrong"
+		client, err := authn.NewClient(config)
+		require.NoError(t, err)
+
+		_, err = client.GetAccount("1")
+		assert.EqualError(t, err, "received 401 from "+server.URL+"/accounts/1")
+	})
+
+	t.Run("discovery", func(t *testing.T) {
+		config := server.Config()
+		config.Discovery = true
+		client, err := authn.NewClient(config)
+		require.NoError(t, err)
+
+		_, err = client.ClaimsFrom(server.Issuer.MustToken())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,163 @@
+package authn
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error codes defined by RFC 6750
+const (
+	BearerErrorInvalidRequest    = "invalid_request"
+	BearerErrorInvalidToken      = "invalid_token"
+	BearerErrorInsufficientScope = "insufficient_scope"
+
+	// defined by RFC 9470
+	BearerErrorInsufficientUserAuthentication = "insufficient_user_authentication"
+)
+
+// ErrorWriter renders the response for a request which failed authentication
+type ErrorWriter interface {
+	WriteError(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// ErrorWriterFunc adapts an ordinary function to an ErrorWriter
+type ErrorWriterFunc func(w http.ResponseWriter, r *http.Request, err error)
+
+// WriteError implements ErrorWriter
+func (f ErrorWriterFunc) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	f(w, r, err)
+}
+
+// BearerError describes an RFC 6750 error response
+type BearerError struct {
+	StatusCode  int
+	Code        string // empty if the request carried no token at all
+	Description string
+	MaxAge      time.Duration // the required authentication age, if any (RFC 9470)
+}
+
+// BearerErrorFrom maps err, as returned by a TokenExtractor or
+// Client.ClaimsFrom, to an RFC 6750 error response. Verification errors
+// are described by their kind only and other errors by a fixed text, so
+// the details of the cause stay on the server. Authentications older than required map to the RFC 9470
+// insufficient_user_authentication error. Failures to fetch signing keys
+// are not the client's fault and map to 503 Service Unavailable.
+func BearerErrorFrom(err error) *BearerError {
+	if err == ErrNoToken {
+		// RFC 6750 section 3.1: no error code if the request lacks any authentication information
+		return &BearerError{StatusCode: http.StatusUnauthorized}
+	}
+
+	var verr *VerificationError
+	if !errors.As(err, &verr) {
+		return &BearerError{
+			StatusCode:  http.StatusUnauthorized,
+			Code:        BearerErrorInvalidToken,
+			Description: "invalid token",
+		}
+	}
+	var aerr *AuthAgeError
+	if verr.Kind == ErrAuthTooOld && errors.As(verr.Err, &aerr) {
+		return &BearerError{
+			StatusCode:  http.StatusUnauthorized,
+			Code:        BearerErrorInsufficientUserAuthentication,
+			Description: verr.Kind.Error(),
+			MaxAge:      aerr.MaxAge,
+		}
+	}
+	if verr.Kind == ErrKeyFetch {
+		return &BearerError{
+			StatusCode:  http.StatusServiceUnavailable,
+			Description: verr.Kind.Error(),
+		}
+	}
+	return &BearerError{
+		StatusCode:  http.StatusUnauthorized,
+		Code:        BearerErrorInvalidToken,
+		Description: verr.Kind.Error(),
+	}
+}
+
+// WWWAuthenticate returns the value of the WWW-Authenticate header for e
+func (e *BearerError) WWWAuthenticate(realm string) string {
+	var params []string
+	if realm != "" {
+		params = append(params, `realm="`+quotable(realm)+`"`)
+	}
+	if e.Code != "" {
+		params = append(params, `error="`+e.Code+`"`)
+	}
+	if e.Description != "" {
+		params = append(params, `error_description="`+quotable(e.Description)+`"`)
+	}
+	if e.MaxAge > 0 {
+		params = append(params, "max_age="+strconv.FormatInt(int64(e.MaxAge/time.Second), 10))
+	}
+
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// problem is an RFC 7807 problem details object
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// BearerErrorWriter is an ErrorWriter which responds with the status code
+// and WWW-Authenticate header described by RFC 6750. The header is only
+// sent along with 401 Unauthorized.
+type BearerErrorWriter struct {
+	Realm string // optional realm of the WWW-Authenticate challenge
+
+	// ProblemJSON makes the writer respond with an application/problem+json
+	// body (RFC 7807) instead of the plain status text
+	ProblemJSON bool
+
+	// Render, if set, replaces the writing of the status code and body. Any
+	// WWW-Authenticate header is already set when Render is called.
+	Render func(w http.ResponseWriter, r *http.Request, e *BearerError)
+}
+
+// WriteError implements ErrorWriter
+func (bw BearerErrorWriter) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e := BearerErrorFrom(err)
+	if e.StatusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", e.WWWAuthenticate(bw.Realm))
+	}
+
+	switch {
+	case bw.Render != nil:
+		bw.Render(w, r, e)
+	case bw.ProblemJSON:
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(e.StatusCode)
+		_ = json.NewEncoder(w).Encode(problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(e.StatusCode),
+			Status: e.StatusCode,
+			Detail: e.Description,
+		})
+	default:
+		http.Error(w, http.StatusText(e.StatusCode), e.StatusCode)
+	}
+}
+
+// quotable strips the characters which RFC 6750 does not allow
+// inside of quoted auth-param values
+func quotable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,113 @@
+package authn
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBearerErrorWWWAuthenticate(t *testing.T) {
+	testCases := []struct {
+		err      error
+		realm    string
+		expected string
+	}{
+		{ErrNoToken, "", `Bearer`},
+		{ErrNoToken, "example", `Bearer realm="example"`},
+		{errors.New("extractor failed: secret detail"), "", `Bearer error="invalid_token", error_description="invalid token"`},
+		{errors.New(`bad "quotes" \ here`), "ex\"ample", `Bearer realm="example", error="invalid_token", error_description="invalid token"`},
+		{newVerificationError(ErrTokenExpired, jwt.ErrExpired), "", `Bearer error="invalid_token", error_description="token expired"`},
+		{newVerificationError(ErrAuthTooOld, &AuthAgeError{MaxAge: time.Hour}), "", `Bearer error="insufficient_user_authentication", error_description="authentication too old", max_age=3600`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			assert.Equal(t, tc.expected, BearerErrorFrom(tc.err).WWWAuthenticate(tc.realm))
+		})
+	}
+
+	custom := &BearerError{Code: BearerErrorInvalidToken, Description: `bad "quotes" \ here`}
+	assert.Equal(t, `Bearer error="invalid_token", error_description="bad quotes  here"`, custom.WWWAuthenticate(""))
+}
+
+func TestBearerErrorWriter(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	t.Run("plain", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		BearerErrorWriter{Realm: "example"}.WriteError(w, r, newVerificationError(ErrTokenExpired, jwt.ErrExpired))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="example", error="invalid_token", error_description="token expired"`, w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, "Unauthorized\n", w.Body.String())
+	})
+
+	t.Run("key fetch failure", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		BearerErrorWriter{}.WriteError(w, r, newVerificationError(ErrKeyFetch, errors.New("connection refused")))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "", w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("problem+json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		BearerErrorWriter{ProblemJSON: true}.WriteError(w, r, newVerificationError(ErrTokenExpired, jwt.ErrExpired))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"token expired"}`, w.Body.String())
+	})
+
+	t.Run("custom render", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		BearerErrorWriter{
+			Render: func(w http.ResponseWriter, r *http.Request, e *BearerError) {
+				w.WriteHeader(e.StatusCode)
+				_, _ = w.Write([]byte(e.Code))
+			},
+		}.WriteError(w, r, newVerificationError(ErrTokenExpired, jwt.ErrExpired))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer error="invalid_token", error_description="token expired"`, w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, "invalid_token", w.Body.String())
+	})
+}
+
+func TestMiddlewareRespondWith(t *testing.T) {
+	client, _ := newMiddlewareTestClient(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not be called")
+	})
+
+	t.Run("default", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		client.Middleware()(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		var rejected error
+		mw := client.Middleware(RespondWith(ErrorWriterFunc(func(w http.ResponseWriter, r *http.Request, err error) {
+			rejected = err
+			w.WriteHeader(http.StatusTeapot)
+		})))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer a.b.c")
+		w := httptest.NewRecorder()
+		mw(handler).ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusTeapot, w.Code)
+		require.Error(t, rejected)
+		assert.NotEqual(t, ErrNoToken, rejected)
+	})
+}
@@ -0,0 +1,128 @@
+package authn
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+)
+
+// ClaimsCache remembers the claims of ID tokens whose signature has been
+// verified, so that repeated verifications of the same token skip parsing
+// and the signature check. The issuer, audience and time based claims are
+// still validated on every verification.
+//
+// Tokens are cached until their own exp claim, never longer, and tokens
+// without exp are not cached at all. When the cache is full, the least
+// recently used token is evicted. A ClaimsCache is safe for concurrent use,
+// but should only be shared among verifiers using the same keys.
+type ClaimsCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List //of *claimsCacheEntry, most recently used first
+	hits    uint64
+	misses  uint64
+}
+
+type claimsCacheEntry struct {
+	key       [sha256.Size]byte
+	claims    Claims
+	expiresAt time.Time
+}
+
+// ClaimsCacheStats are the counters of a ClaimsCache
+type ClaimsCacheStats struct {
+	Hits   uint64 //verifications served from the cache
+	Misses uint64 //verifications which had to check the signature
+	Size   int    //tokens currently cached
+}
+
+// NewClaimsCache returns a ClaimsCache holding up to size tokens
+func NewClaimsCache(size int) *ClaimsCache {
+	return &ClaimsCache{
+		size:    size,
+		entries: map[[sha256.Size]byte]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Stats returns the current counters of c
+func (c *ClaimsCache) Stats() ClaimsCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ClaimsCacheStats{Hits: c.hits, Misses: c.misses, Size: c.lru.Len()}
+}
+
+// get returns a copy of the cached claims of idToken, unless they expired by now
+func (c *ClaimsCache) get(idToken string, now time.Time) (*Claims, bool) {
+	key := sha256.Sum256([]byte(idToken))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry := elem.Value.(*claimsCacheEntry)
+	if !now.Before(entry.expiresAt) {
+		c.remove(elem)
+		c.misses++
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	c.hits++
+	return copyClaims(&entry.claims), true
+}
+
+// add caches a copy of the claims of idToken until their expiry
+func (c *ClaimsCache) add(idToken string, claims *Claims) {
+	if c.size <= 0 || claims.Expiry == nil {
+		return
+	}
+	key := sha256.Sum256([]byte(idToken))
+	entry := &claimsCacheEntry{key: key, claims: *copyClaims(claims), expiresAt: claims.Expiry.Time()}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	if c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+}
+
+// copyClaims returns a deep copy of claims, so that callers cannot modify
+// cached entries
+func copyClaims(claims *Claims) *Claims {
+	copied := *claims
+	copied.AuthTime = copyNumericDate(claims.AuthTime)
+	copied.Expiry = copyNumericDate(claims.Expiry)
+	copied.NotBefore = copyNumericDate(claims.NotBefore)
+	copied.IssuedAt = copyNumericDate(claims.IssuedAt)
+	if claims.Audience != nil {
+		copied.Audience = append(jwt.Audience{}, claims.Audience...)
+	}
+	return &copied
+}
+
+func copyNumericDate(date *jwt.NumericDate) *jwt.NumericDate {
+	if date == nil {
+		return nil
+	}
+	copied := *date
+	return &copied
+}
+
+// remove drops elem from the cache. It must be called with c.mu held.
+func (c *ClaimsCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*claimsCacheEntry).key)
+}
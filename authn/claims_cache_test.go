@@ -0,0 +1,107 @@
+package authn
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimsCache(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	claims := func(exp time.Time) *Claims {
+		return &Claims{Claims: jwt.Claims{Subject: "42", Expiry: jwt.NewNumericDate(exp)}}
+	}
+
+	t.Run("until expiry", func(t *testing.T) {
+		cache := NewClaimsCache(10)
+		cache.add("token", claims(now.Add(time.Minute)))
+
+		cached, ok := cache.get("token", now.Add(59*time.Second))
+		require.True(t, ok)
+		assert.Equal(t, "42", cached.Subject)
+
+		_, ok = cache.get("token", now.Add(time.Minute))
+		assert.False(t, ok)
+		assert.Equal(t, ClaimsCacheStats{Hits: 1, Misses: 1, Size: 0}, cache.Stats())
+	})
+
+	t.Run("without expiry", func(t *testing.T) {
+		cache := NewClaimsCache(10)
+		cache.add("token", &Claims{Claims: jwt.Claims{Subject: "42"}})
+
+		_, ok := cache.get("token", now)
+		assert.False(t, ok)
+	})
+
+	t.Run("least recently used eviction", func(t *testing.T) {
+		cache := NewClaimsCache(2)
+		cache.add("a", claims(now.Add(time.Minute)))
+		cache.add("b", claims(now.Add(time.Minute)))
+		_, ok := cache.get("a", now)
+		require.True(t, ok)
+		cache.add("c", claims(now.Add(time.Minute)))
+
+		_, ok = cache.get("b", now)
+		assert.False(t, ok)
+		_, ok = cache.get("a", now)
+		assert.True(t, ok)
+		_, ok = cache.get("c", now)
+		assert.True(t, ok)
+		assert.Equal(t, 2, cache.Stats().Size)
+	})
+
+	t.Run("copies", func(t *testing.T) {
+		cache := NewClaimsCache(10)
+		original := claims(now.Add(time.Minute))
+		original.Audience = jwt.Audience{"app.example.com"}
+		cache.add("token", original)
+		*original.Expiry = *jwt.NewNumericDate(now.Add(time.Hour))
+
+		cached, ok := cache.get("token", now)
+		require.True(t, ok)
+		cached.Subject = "changed"
+		cached.Audience[0] = "other.example.com"
+		*cached.Expiry = *jwt.NewNumericDate(now.Add(time.Hour))
+
+		cached, ok = cache.get("token", now)
+		require.True(t, ok)
+		assert.Equal(t, "42", cached.Subject)
+		assert.Equal(t, jwt.Audience{"app.example.com"}, cached.Audience)
+		assert.Equal(t, jwt.NewNumericDate(now.Add(time.Minute)), cached.Expiry)
+	})
+}
+
+func TestIDTokenVerifierClaimsCache(t *testing.T) {
+	key := newTestKey(t, "kid1")
+	jwks := key.provider()
+	clock := newMockClock()
+	cache := NewClaimsCache(10)
+	verifier, err := NewIDTokenVerifier("https://authn.example.com", "app.example.com", jwks, WithClock(clock), WithClaimsCache(cache))
+	require.NoError(t, err)
+	token := key.sign(t, testClaims(clock.Now()))
+
+	for i := 0; i < 3; i++ {
+		claims, err := verifier.GetVerifiedClaims(token)
+		require.NoError(t, err)
+		assert.Equal(t, "42", claims.Subject)
+	}
+	assert.Equal(t, 1, jwks.hits())
+	assert.Equal(t, ClaimsCacheStats{Hits: 2, Misses: 1, Size: 1}, cache.Stats())
+
+	// cached tokens are still validated against the expectations of the verifier
+	other, err := NewIDTokenVerifier("https://authn.example.com", "other.example.com", jwks, WithClock(clock), WithClaimsCache(cache))
+	require.NoError(t, err)
+	_, err = other.GetVerifiedClaims(token)
+	assert.True(t, errors.Is(err, ErrInvalidAudience))
+	assert.Equal(t, 1, jwks.hits())
+
+	// and expire with the token
+	clock.Add(time.Hour + 2*jwt.DefaultLeeway)
+	_, err = verifier.GetVerifiedClaims(token)
+	assert.True(t, errors.Is(err, ErrTokenExpired))
+	assert.Equal(t, 2, jwks.hits())
+}
@@ -0,0 +1,17 @@
+package authn
+
+import "time"
+
+// Clock provides the current time for verifying tokens and expiring
+// cached keys. Inject a fake to make time-dependent behavior
+// deterministic in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock used unless configured otherwise
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -1,7 +1,19 @@
 package authn
 
+import (
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+)
+
 const (
-	DefaultKeychainTTL = 60
+	DefaultKeychainTTL        = 60
+	DefaultKeyRefreshInterval = 30 * time.Second
+	DefaultNegativeCacheTTL   = 5 * time.Minute
+	DefaultNegativeCacheSize  = 1000
+	DefaultKeySnapshotMaxAge  = 24 * time.Hour
+	DefaultSessionCookieName  = "authn"
 )
 
 // Config is a configuration struct for Client
@@ -12,13 +24,66 @@ type Config struct {
 	Username       string //the http basic auth username for accessing private endpoints of the authn issuer
 	Password       string //the http basic auth password for accessing private endpoints of the authn issuer
 	KeychainTTL    int    //TTL for a key in keychain in minutes
+	Discovery      bool   //look up the JWKS location in the configuration document of PrivateBaseURL instead of assuming PrivateBaseURL/jwks
+
+	KeyRefreshInterval time.Duration //minimum time between key set refreshes caused by unknown key IDs. defaults to DefaultKeyRefreshInterval
+	NegativeCacheTTL   time.Duration //how long key IDs missing from a fresh key set are remembered. defaults to DefaultNegativeCacheTTL
+	NegativeCacheSize  int           //maximum number of remembered missing key IDs. defaults to DefaultNegativeCacheSize
+
+	BackgroundKeyRefresh bool          //refresh keys in the background ahead of expiry. stop it with Client.Close
+	MaxKeyStaleness      time.Duration //how long expired keys are still used while they are refreshed. defaults to 0
+	OnKeyRefreshError    func(error)   //called whenever refreshing or persisting keys fails
+
+	KeySnapshotPath   string        //file to persist fetched keys in and to load them from in NewClient. disabled if empty
+	KeySnapshotMaxAge time.Duration //age after which persisted keys are ignored. defaults to DefaultKeySnapshotMaxAge
+
+	Leeway time.Duration //tolerated clock skew when validating exp, nbf and iat. defaults to jwt.DefaultLeeway, negative for none
+	Clock  Clock         //source of the current time for verification and key expiry. defaults to the system clock
+
+	SigningAlgorithms []jose.SignatureAlgorithm //signing algorithms accepted in ID tokens. defaults to DefaultAlgorithms()
+
+	ClaimsCacheSize int //maximum number of tokens with a verified signature remembered until their expiry. disabled if 0
+
+	AudiencePolicy AudiencePolicy //overrides Audience with the audiences accepted by the policy, e.g. a WildcardAudiencePolicy
+
+	Origin            string //sent as Origin header to the public API. must be one of AuthN's APP_DOMAINS. defaults to https://Audience
+	SessionCookieName string //name of AuthN's session cookie. defaults to DefaultSessionCookieName
 }
 
 func (c *Config) setDefaults() {
 	if c.KeychainTTL == 0 {
 		c.KeychainTTL = DefaultKeychainTTL
 	}
+	if c.KeyRefreshInterval == 0 {
+		c.KeyRefreshInterval = DefaultKeyRefreshInterval
+	}
+	if c.NegativeCacheTTL == 0 {
+		c.NegativeCacheTTL = DefaultNegativeCacheTTL
+	}
+	if c.NegativeCacheSize == 0 {
+		c.NegativeCacheSize = DefaultNegativeCacheSize
+	}
+	if c.KeySnapshotMaxAge == 0 {
+		c.KeySnapshotMaxAge = DefaultKeySnapshotMaxAge
+	}
+	if c.Origin == "" && c.Audience != "" {
+		c.Origin = "https://" + c.Audience
+	}
+	if c.SessionCookieName == "" {
+		c.SessionCookieName = DefaultSessionCookieName
+	}
 	if c.PrivateBaseURL == "" {
 		c.PrivateBaseURL = c.Issuer
 	}
+	if c.Leeway == 0 {
+		c.Leeway = jwt.DefaultLeeway
+	} else if c.Leeway < 0 {
+		c.Leeway = 0
+	}
+	if c.Clock == nil {
+		c.Clock = systemClock{}
+	}
+	if len(c.SigningAlgorithms) == 0 {
+		c.SigningAlgorithms = DefaultAlgorithms()
+	}
 }
@@ -2,6 +2,10 @@ package authn
 
 import (
 	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	jwt "github.com/go-jose/go-jose/v3/jwt"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,16 +24,28 @@ func TestConfigDefaults(t *testing.T) {
 	assert.Equal(t, c.Username, "test_username")
 	assert.Equal(t, c.Password, "test_password")
 	assert.Equal(t, c.KeychainTTL, DefaultKeychainTTL)
+	assert.Equal(t, c.KeyRefreshInterval, DefaultKeyRefreshInterval)
+	assert.Equal(t, c.NegativeCacheTTL, DefaultNegativeCacheTTL)
+	assert.Equal(t, c.NegativeCacheSize, DefaultNegativeCacheSize)
+	assert.Equal(t, c.KeySnapshotMaxAge, DefaultKeySnapshotMaxAge)
+	assert.Equal(t, c.Leeway, jwt.DefaultLeeway)
+	assert.Equal(t, c.Clock, systemClock{})
+	assert.Equal(t, c.SigningAlgorithms, DefaultAlgorithms())
+	assert.Equal(t, c.Origin, "https://test_audience")
+	assert.Equal(t, c.SessionCookieName, DefaultSessionCookieName)
 }
 
 func TestConfigDefaultsOverride(t *testing.T) {
 	c := Config{
-		Issuer:         "test_issuer",
-		Audience:       "test_audience",
-		Username:       "test_username",
-		Password:       "test_password",
-		PrivateBaseURL: "test_private_url",
-		KeychainTTL:    500,
+		Issuer:            "test_issuer",
+		Audience:          "test_audience",
+		Username:          "test_username",
+		Password:          "test_password",
+		PrivateBaseURL:    "test_private_url",
+		KeychainTTL:       500,
+		Leeway:            5 * time.Second,
+		Clock:             newMockClock(),
+		SigningAlgorithms: []jose.SignatureAlgorithm{jose.ES256},
 	}
 	c.setDefaults()
 	assert.Equal(t, c.Issuer, "test_issuer")
@@ -38,4 +54,13 @@ func TestConfigDefaultsOverride(t *testing.T) {
 	assert.Equal(t, c.Username, "test_username")
 	assert.Equal(t, c.Password, "test_password")
 	assert.Equal(t, c.KeychainTTL, 500)
+	assert.Equal(t, c.Leeway, 5*time.Second)
+	assert.Equal(t, c.Clock, newMockClock())
+	assert.Equal(t, c.SigningAlgorithms, []jose.SignatureAlgorithm{jose.ES256})
+}
+
+func TestConfigNoLeeway(t *testing.T) {
+	c := Config{Leeway: -1}
+	c.setDefaults()
+	assert.Equal(t, c.Leeway, time.Duration(0))
 }
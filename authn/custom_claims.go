@@ -0,0 +1,56 @@
+package authn
+
+import (
+	"context"
+	"fmt"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+)
+
+// VerifyInto verifies idToken exactly like ClaimsFrom and then decodes its
+// complete payload into dest, which must be a pointer to a struct or map.
+// Use it to access claims which Claims does not know about.
+func (ac *Client) VerifyInto(idToken string, dest interface{}) error {
+	return ac.VerifyIntoContext(context.Background(), idToken, dest)
+}
+
+// VerifyIntoContext works like VerifyInto but honors the cancellation and
+// deadline of ctx while fetching signing keys.
+func (ac *Client) VerifyIntoContext(ctx context.Context, idToken string, dest interface{}) error {
+	_, err := ac.ClaimsFromContext(ctx, idToken)
+	if err != nil {
+		return err
+	}
+	return decodeClaims(idToken, dest)
+}
+
+// VerifyAs works like Client.VerifyInto but returns a new T holding the
+// payload of idToken.
+func VerifyAs[T any](ac *Client, idToken string) (*T, error) {
+	return VerifyAsContext[T](context.Background(), ac, idToken)
+}
+
+// VerifyAsContext works like VerifyAs but honors the cancellation and
+// deadline of ctx while fetching signing keys.
+func VerifyAsContext[T any](ctx context.Context, ac *Client, idToken string) (*T, error) {
+	dest := new(T)
+	err := ac.VerifyIntoContext(ctx, idToken, dest)
+	if err != nil {
+		return nil, err
+	}
+	return dest, nil
+}
+
+// decodeClaims unmarshals the payload of idToken into dest. The signature
+// is not checked, so idToken must have been verified before.
+func decodeClaims(idToken string, dest interface{}) error {
+	idJwt, err := jwt.ParseSigned(idToken)
+	if err != nil {
+		return err
+	}
+	err = idJwt.UnsafeClaimsWithoutVerification(dest)
+	if err != nil {
+		return fmt.Errorf("decoding claims: %w", err)
+	}
+	return nil
+}
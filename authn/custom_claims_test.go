@@ -0,0 +1,74 @@
+package authn
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type customClaims struct {
+	Claims
+	TenantID string   `json:"tenant_id"`
+	Roles    []string `json:"roles"`
+}
+
+func TestVerifyInto(t *testing.T) {
+	client, key := newMiddlewareTestClient(t)
+
+	sign := func(audience string) string {
+		claims := testClaims(time.Now())
+		claims.Audience = jwt.Audience{audience}
+		return key.sign(t, claims, map[string]interface{}{
+			"sid":       "session",
+			"tenant_id": "acme",
+			"roles":     []string{"admin", "billing"},
+		})
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		claims := customClaims{}
+		err := client.VerifyInto(sign("app.example.com"), &claims)
+		require.NoError(t, err)
+		assert.Equal(t, "42", claims.Subject)
+		assert.Equal(t, "session", claims.SessionID)
+		assert.Equal(t, "acme", claims.TenantID)
+		assert.Equal(t, []string{"admin", "billing"}, claims.Roles)
+	})
+
+	t.Run("map", func(t *testing.T) {
+		claims := map[string]interface{}{}
+		err := client.VerifyInto(sign("app.example.com"), &claims)
+		require.NoError(t, err)
+		assert.Equal(t, "acme", claims["tenant_id"])
+	})
+
+	t.Run("generic", func(t *testing.T) {
+		claims, err := VerifyAs[customClaims](client, sign("app.example.com"))
+		require.NoError(t, err)
+		assert.Equal(t, "42", claims.Subject)
+		assert.Equal(t, "acme", claims.TenantID)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		claims := customClaims{}
+		err := client.VerifyInto(sign("other.example.com"), &claims)
+		assert.True(t, errors.Is(err, ErrInvalidAudience))
+		assert.Equal(t, customClaims{}, claims)
+
+		generic, err := VerifyAs[customClaims](client, sign("other.example.com"))
+		assert.True(t, errors.Is(err, ErrInvalidAudience))
+		assert.Nil(t, generic)
+	})
+
+	t.Run("mismatching type", func(t *testing.T) {
+		claims := struct {
+			TenantID int `json:"tenant_id"`
+		}{}
+		err := client.VerifyInto(sign("app.example.com"), &claims)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,103 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrIssuerMismatch is returned if the issuer advertised by the AuthN server's
+// configuration document differs from Config.Issuer
+var ErrIssuerMismatch = errors.New("discovered issuer does not match the configured issuer")
+
+// discoveryPaths are tried in order to find the configuration document.
+// Older AuthN servers only serve the latter.
+var discoveryPaths = []string{".well-known/openid-configuration", "configuration"}
+
+// discoveryDocument holds the parts of the configuration document we need
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// enableDiscovery makes ic look up the JWKS location in the AuthN server's
+// configuration document, which must advertise issuer
+func (ic *internalClient) enableDiscovery(issuer string) {
+	ic.discovery = true
+	ic.issuer = issuer
+}
+
+// jwksURL returns the location of the JWKS. Discovered locations are cached
+// for the lifetime of ic.
+func (ic *internalClient) jwksURL(ctx context.Context) (string, error) {
+	if !ic.discovery {
+		return ic.absoluteURL("jwks"), nil
+	}
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if ic.discoveredJWKSURL != "" {
+		return ic.discoveredJWKSURL, nil
+	}
+
+	doc, err := ic.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	if doc.Issuer != ic.issuer {
+		return "", fmt.Errorf("%w: got %q, want %q", ErrIssuerMismatch, doc.Issuer, ic.issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("no jwks_uri in configuration document of %s", ic.baseURL)
+	}
+	jwksURL, err := ic.baseURL.Parse(doc.JWKSURI)
+	if err != nil {
+		return "", err
+	}
+
+	ic.discoveredJWKSURL = jwksURL.String()
+	return ic.discoveredJWKSURL, nil
+}
+
+// discover fetches the first configuration document found at discoveryPaths
+func (ic *internalClient) discover(ctx context.Context) (*discoveryDocument, error) {
+	var err error
+	for _, path := range discoveryPaths {
+		var doc *discoveryDocument
+		doc, err = ic.fetchDiscoveryDocument(ctx, ic.absoluteURL(path))
+		if err != errDiscoveryNotFound {
+			return doc, err
+		}
+	}
+	return nil, fmt.Errorf("no configuration document found at %s", ic.baseURL)
+}
+
+var errDiscoveryNotFound = errors.New("configuration document not found")
+
+func (ic *internalClient) fetchDiscoveryDocument(ctx context.Context, documentURL string) (*discoveryDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, get, documentURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ic.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errDiscoveryNotFound
+	}
+	if !isStatusSuccess(resp.StatusCode) {
+		return nil, fmt.Errorf("Received %d from %s", resp.StatusCode, documentURL)
+	}
+
+	doc := &discoveryDocument{}
+	err = json.NewDecoder(resp.Body).Decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
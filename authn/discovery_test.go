@@ -0,0 +1,114 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestICDiscovery(t *testing.T) {
+	testCases := []struct {
+		name      string
+		documents map[string]string
+		jwksURL   string
+		err       error
+	}{
+		{
+			name: "well-known document",
+			documents: map[string]string{
+				"/.well-known/openid-configuration": `{"issuer": "https://authn.example.com", "jwks_uri": "https://gateway.example.com/authn/jwks"}`,
+			},
+			jwksURL: "https://gateway.example.com/authn/jwks",
+		},
+		{
+			name: "configuration document",
+			documents: map[string]string{
+				"/configuration": `{"issuer": "https://authn.example.com", "jwks_uri": "https://gateway.example.com/authn/jwks"}`,
+			},
+			jwksURL: "https://gateway.example.com/authn/jwks",
+		},
+		{
+			name: "relative jwks_uri",
+			documents: map[string]string{
+				"/configuration": `{"issuer": "https://authn.example.com", "jwks_uri": "/keys"}`,
+			},
+			jwksURL: "http://private.example.com/keys",
+		},
+		{
+			name: "issuer mismatch",
+			documents: map[string]string{
+				"/configuration": `{"issuer": "https://authn.elsewhere.com", "jwks_uri": "https://authn.elsewhere.com/jwks"}`,
+			},
+			err: ErrIssuerMismatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				doc, ok := tc.documents[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(doc))
+			})
+			httpClient, teardown := testingHTTPClient(h)
+			defer teardown()
+
+			cli, err := newInternalClient("http://private.example.com", "username", "password")
+			require.NoError(t, err)
+			cli.client = httpClient
+			cli.enableDiscovery("https://authn.example.com")
+
+			jwksURL, err := cli.jwksURL(context.Background())
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.jwksURL, jwksURL)
+
+			// the location is cached
+			discoveryRequests := requests
+			jwksURL, err = cli.jwksURL(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tc.jwksURL, jwksURL)
+			assert.Equal(t, discoveryRequests, requests)
+		})
+	}
+}
+
+func TestICDiscoveryKeySet(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/configuration":
+			_, _ = w.Write([]byte(`{"issuer": "https://authn.example.com", "jwks_uri": "http://gateway.example.com/authn/jwks"}`))
+		case "/authn/jwks":
+			assert.Equal(t, "gateway.example.com", r.Host)
+			_, _ = w.Write([]byte(`{"keys": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	cli, err := newInternalClient("http://private.example.com", "username", "password")
+	require.NoError(t, err)
+	cli.client = httpClient
+
+	_, err = cli.KeySet(context.Background())
+	assert.EqualError(t, err, "Received 404 from http://private.example.com/jwks")
+
+	cli.enableDiscovery("https://authn.example.com")
+	jwks, err := cli.KeySet(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, jwks.Keys, 0)
+}
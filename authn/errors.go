@@ -0,0 +1,84 @@
+package authn
+
+import (
+	"errors"
+
+	jose "github.com/go-jose/go-jose/v3"
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+)
+
+// Kinds of verification failures. Every error returned while verifying an
+// idToken is a *VerificationError which matches exactly one of these with
+// errors.Is, e.g. errors.Is(err, authn.ErrTokenExpired).
+var (
+	ErrMalformedToken      = errors.New("malformed token")
+	ErrUnknownKey          = errors.New("unknown signing key")
+	ErrInvalidSignature    = errors.New("invalid signature")
+	ErrDisallowedAlgorithm = errors.New("signing algorithm not allowed")
+	ErrTokenExpired        = errors.New("token expired")
+	ErrTokenNotYetValid    = errors.New("token not yet valid")
+	ErrInvalidIssuer       = errors.New("invalid issuer")
+	ErrInvalidAudience     = errors.New("invalid audience")
+	ErrKeyFetch            = errors.New("signing keys could not be fetched")
+	ErrAuthTooOld          = errors.New("authentication too old")
+)
+
+// ErrNoKey is returned if the keychain has no key for the token's key ID. It is
+// wrapped in a *VerificationError, so match it with errors.Is.
+//
+// Deprecated: use ErrUnknownKey, which ErrNoKey is an alias of.
+var ErrNoKey = ErrUnknownKey
+
+// VerificationError is returned if an idToken does not verify. Kind tells
+// why in a stable way while Err keeps the underlying cause, usually an error
+// of go-jose, for debugging purposes.
+type VerificationError struct {
+	Kind error
+	Err  error
+}
+
+func newVerificationError(kind, err error) *VerificationError {
+	return &VerificationError{Kind: kind, Err: err}
+}
+
+// Error implements the error interface
+func (e *VerificationError) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying cause
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of e
+func (e *VerificationError) Is(target error) bool {
+	return e.Kind == target
+}
+
+// signatureError classifies an error of jwt.JSONWebToken.Claims
+func signatureError(err error) *VerificationError {
+	if err == jose.ErrCryptoFailure {
+		return newVerificationError(ErrInvalidSignature, err)
+	}
+	return newVerificationError(ErrMalformedToken, err)
+}
+
+// validationError classifies an error of jwt.Claims.Validate
+func validationError(err error) *VerificationError {
+	switch err {
+	case jwt.ErrExpired:
+		return newVerificationError(ErrTokenExpired, err)
+	case jwt.ErrNotValidYet, jwt.ErrIssuedInTheFuture:
+		return newVerificationError(ErrTokenNotYetValid, err)
+	case jwt.ErrInvalidIssuer:
+		return newVerificationError(ErrInvalidIssuer, err)
+	case jwt.ErrInvalidAudience:
+		return newVerificationError(ErrInvalidAudience, err)
+	default:
+		return newVerificationError(ErrMalformedToken, err)
+	}
+}
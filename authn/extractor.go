@@ -0,0 +1,89 @@
+package authn
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrNoToken is returned by a TokenExtractor if the request does not carry a token
+var ErrNoToken = errors.New("no token found in request")
+
+// TokenExtractor finds the idToken in an HTTP request. Implementations
+// return ErrNoToken if the request does not carry one.
+type TokenExtractor interface {
+	ExtractToken(r *http.Request) (string, error)
+}
+
+// TokenExtractorFunc adapts an ordinary function to a TokenExtractor
+type TokenExtractorFunc func(r *http.Request) (string, error)
+
+// ExtractToken implements TokenExtractor
+func (f TokenExtractorFunc) ExtractToken(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// BearerTokenExtractor extracts the token from an "Authorization: Bearer"
+// header. Other authorization schemes are ignored.
+func BearerTokenExtractor() TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		const prefix = "bearer "
+
+		header := r.Header.Get("Authorization")
+		if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+			return "", ErrNoToken
+		}
+		return nonEmptyToken(header[len(prefix):])
+	})
+}
+
+// HeaderTokenExtractor extracts the token from the raw value of the
+// header with the given name
+func HeaderTokenExtractor(name string) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		return nonEmptyToken(r.Header.Get(name))
+	})
+}
+
+// CookieTokenExtractor extracts the token from the cookie with the given name
+func CookieTokenExtractor(name string) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return "", ErrNoToken
+		}
+		return nonEmptyToken(cookie.Value)
+	})
+}
+
+// QueryTokenExtractor extracts the token from the URL query parameter with
+// the given name. Tokens in URLs tend to end up in logs, so only use this
+// where no alternative exists, e.g. for WebSocket upgrades.
+func QueryTokenExtractor(param string) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		return nonEmptyToken(r.URL.Query().Get(param))
+	})
+}
+
+// ChainTokenExtractors tries each extractor in order and returns the first
+// token found. Errors other than ErrNoToken abort the chain.
+func ChainTokenExtractors(extractors ...TokenExtractor) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		for _, extractor := range extractors {
+			token, err := extractor.ExtractToken(r)
+			if err == ErrNoToken {
+				continue
+			}
+			return token, err
+		}
+		return "", ErrNoToken
+	})
+}
+
+func nonEmptyToken(token string) (string, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrNoToken
+	}
+	return token, nil
+}
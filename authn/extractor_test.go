@@ -0,0 +1,81 @@
+package authn
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenExtractors(t *testing.T) {
+	newRequest := func(target string, header http.Header) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		return r
+	}
+
+	testCases := []struct {
+		name      string
+		extractor TokenExtractor
+		request   *http.Request
+		token     string
+		err       error
+	}{
+		{"bearer", BearerTokenExtractor(), newRequest("/", http.Header{"Authorization": {"Bearer abc"}}), "abc", nil},
+		{"bearer lowercase", BearerTokenExtractor(), newRequest("/", http.Header{"Authorization": {"bearer abc"}}), "abc", nil},
+		{"bearer other scheme", BearerTokenExtractor(), newRequest("/", http.Header{"Authorization": {"Basic abc"}}), "", ErrNoToken},
+		{"bearer empty", BearerTokenExtractor(), newRequest("/", http.Header{"Authorization": {"Bearer  "}}), "", ErrNoToken},
+		{"bearer missing", BearerTokenExtractor(), newRequest("/", nil), "", ErrNoToken},
+		{"header", HeaderTokenExtractor("X-Id-Token"), newRequest("/", http.Header{"X-Id-Token": {"abc"}}), "abc", nil},
+		{"header missing", HeaderTokenExtractor("X-Id-Token"), newRequest("/", nil), "", ErrNoToken},
+		{"cookie", CookieTokenExtractor("id_token"), newRequest("/", http.Header{"Cookie": {"other=x; id_token=abc"}}), "abc", nil},
+		{"cookie missing", CookieTokenExtractor("id_token"), newRequest("/", http.Header{"Cookie": {"other=x"}}), "", ErrNoToken},
+		{"query", QueryTokenExtractor("token"), newRequest("/ws?token=abc", nil), "abc", nil},
+		{"query missing", QueryTokenExtractor("token"), newRequest("/ws?other=abc", nil), "", ErrNoToken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := tc.extractor.ExtractToken(tc.request)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.token, token)
+		})
+	}
+}
+
+func TestChainTokenExtractors(t *testing.T) {
+	chain := ChainTokenExtractors(
+		BearerTokenExtractor(),
+		CookieTokenExtractor("id_token"),
+		QueryTokenExtractor("token"),
+	)
+
+	t.Run("first match wins", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/?token=query", nil)
+		r.Header.Set("Cookie", "id_token=cookie")
+		token, err := chain.ExtractToken(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "cookie", token)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		_, err := chain.ExtractToken(r)
+		assert.Equal(t, ErrNoToken, err)
+	})
+
+	t.Run("errors abort", func(t *testing.T) {
+		failure := errors.New("malformed request")
+		chain := ChainTokenExtractors(
+			TokenExtractorFunc(func(r *http.Request) (string, error) { return "", failure }),
+			QueryTokenExtractor("token"),
+		)
+		r := httptest.NewRequest(http.MethodGet, "/?token=query", nil)
+		_, err := chain.ExtractToken(r)
+		assert.Equal(t, failure, err)
+	})
+}
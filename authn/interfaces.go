@@ -1,6 +1,8 @@
 package authn
 
 import (
+	"context"
+
 	jose "github.com/go-jose/go-jose/v3"
 )
 
@@ -11,7 +13,44 @@ type JWKProvider interface {
 	Key(kid string) ([]jose.JSONWebKey, error)
 }
 
+// JWKProviderContext is a JWKProvider which honors the cancellation
+// and deadline of a context while looking up a key
+type JWKProviderContext interface {
+	JWKProvider
+	KeyContext(ctx context.Context, kid string) ([]jose.JSONWebKey, error)
+}
+
+// jwkSetProvider provides the complete JSON Web Key Set of an issuer
+type jwkSetProvider interface {
+	KeySet(ctx context.Context) (*jose.JSONWebKeySet, error)
+}
+
 // Extracts verified in-built claims from a jwt idToken
 type JWTClaimsExtractor interface {
 	GetVerifiedClaims(idToken string) (*Claims, error)
 }
+
+// JWTClaimsExtractorContext is a JWTClaimsExtractor which honors the
+// cancellation and deadline of a context while verifying an idToken
+type JWTClaimsExtractorContext interface {
+	JWTClaimsExtractor
+	GetVerifiedClaimsContext(ctx context.Context, idToken string) (*Claims, error)
+}
+
+// keyContext looks up kid with provider, passing ctx along if the
+// provider supports it
+func keyContext(ctx context.Context, provider JWKProvider, kid string) ([]jose.JSONWebKey, error) {
+	if p, ok := provider.(JWKProviderContext); ok {
+		return p.KeyContext(ctx, kid)
+	}
+	return provider.Key(kid)
+}
+
+// verifiedClaimsContext verifies idToken with extractor, passing ctx
+// along if the extractor supports it
+func verifiedClaimsContext(ctx context.Context, extractor JWTClaimsExtractor, idToken string) (*Claims, error) {
+	if e, ok := extractor.(JWTClaimsExtractorContext); ok {
+		return e.GetVerifiedClaimsContext(ctx, idToken)
+	}
+	return extractor.GetVerifiedClaims(idToken)
+}
@@ -1,6 +1,7 @@
 package authn
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	jose "github.com/go-jose/go-jose/v3"
@@ -19,14 +21,19 @@ type internalClient struct {
 	baseURL  *url.URL
 	username string
 	password string
+
+	discovery         bool       //whether to discover the JWKS location
+	issuer            string     //the issuer a discovered configuration document must advertise
+	mu                sync.Mutex //guards discoveredJWKSURL
+	discoveredJWKSURL string
 }
 
 const (
-	delete = "DELETE"
-	get    = "GET"
-	patch  = "PATCH"
-	post   = "POST"
-	put    = "PUT"
+	del   = "DELETE"
+	get   = "GET"
+	patch = "PATCH"
+	post  = "POST"
+	put   = "PUT"
 )
 
 func newInternalClient(base, username, password string) (*internalClient, error) {
@@ -49,35 +56,62 @@ func newInternalClient(base, username, password string) (*internalClient, error)
 	}, nil
 }
 
-// TODO: test coverage
+// Key downloads the JWKS of the AuthN server and returns the keys with the given kid
 func (ic *internalClient) Key(kid string) ([]jose.JSONWebKey, error) {
-	resp, err := http.Get(ic.absoluteURL("jwks"))
+	return ic.KeyContext(context.Background(), kid)
+}
+
+// KeyContext works like Key but aborts the JWKS request when ctx is done
+func (ic *internalClient) KeyContext(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	jwks, err := ic.KeySet(ctx)
 	if err != nil {
 		return []jose.JSONWebKey{}, err
 	}
+	return jwks.Key(kid), nil
+}
+
+// KeySet downloads the complete JWKS of the AuthN server
+func (ic *internalClient) KeySet(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	jwksURL, err := ic.jwksURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, get, jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ic.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
 	if !isStatusSuccess(resp.StatusCode) {
-		return []jose.JSONWebKey{}, fmt.Errorf("Received %d from %s", resp.StatusCode, ic.absoluteURL("jwks"))
+		return nil, fmt.Errorf("Received %d from %s", resp.StatusCode, jwksURL)
 	}
 
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return []jose.JSONWebKey{}, err
+		return nil, err
 	}
 
 	jwks := &jose.JSONWebKeySet{}
 
 	err = json.Unmarshal(bodyBytes, jwks)
 	if err != nil {
-		return []jose.JSONWebKey{}, err
+		return nil, err
 	}
-	return jwks.Key(kid), nil
+	return jwks, nil
 }
 
 // GetAccount gets the account details for the specified account id
 func (ic *internalClient) GetAccount(id string) (*Account, error) {
-	resp, err := ic.doWithAuth(get, "accounts/"+id, nil)
+	return ic.GetAccountContext(context.Background(), id)
+}
+
+// GetAccountContext works like GetAccount but honors the cancellation and deadline of ctx
+func (ic *internalClient) GetAccountContext(ctx context.Context, id string) (*Account, error) {
+	resp, err := ic.doWithAuthContext(ctx, get, "accounts/"+id, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -97,39 +131,64 @@ func (ic *internalClient) GetAccount(id string) (*Account, error) {
 
 // Update updates the account with the specified id
 func (ic *internalClient) Update(id, username string) error {
+	return ic.UpdateContext(context.Background(), id, username)
+}
+
+// UpdateContext works like Update but honors the cancellation and deadline of ctx
+func (ic *internalClient) UpdateContext(ctx context.Context, id, username string) error {
 	form := url.Values{}
 	form.Add("username", username)
 
-	_, err := ic.doWithAuth(patch, "accounts/"+id, strings.NewReader(form.Encode()))
+	_, err := ic.doWithAuthContext(ctx, patch, "accounts/"+id, strings.NewReader(form.Encode()))
 	return err
 }
 
 // LockAccount locks the account with the specified id
 func (ic *internalClient) LockAccount(id string) error {
-	_, err := ic.doWithAuth(patch, "accounts/"+id+"/lock", nil)
+	return ic.LockAccountContext(context.Background(), id)
+}
+
+// LockAccountContext works like LockAccount but honors the cancellation and deadline of ctx
+func (ic *internalClient) LockAccountContext(ctx context.Context, id string) error {
+	_, err := ic.doWithAuthContext(ctx, patch, "accounts/"+id+"/lock", nil)
 	return err
 }
 
 // UnlockAccount unlocks the account with the specified id
 func (ic *internalClient) UnlockAccount(id string) error {
-	_, err := ic.doWithAuth(patch, "accounts/"+id+"/unlock", nil)
+	return ic.UnlockAccountContext(context.Background(), id)
+}
+
+// UnlockAccountContext works like UnlockAccount but honors the cancellation and deadline of ctx
+func (ic *internalClient) UnlockAccountContext(ctx context.Context, id string) error {
+	_, err := ic.doWithAuthContext(ctx, patch, "accounts/"+id+"/unlock", nil)
 	return err
 }
 
 // ArchiveAccount archives the account with the specified id
 func (ic *internalClient) ArchiveAccount(id string) error {
-	_, err := ic.doWithAuth(delete, "accounts/"+id, nil)
+	return ic.ArchiveAccountContext(context.Background(), id)
+}
+
+// ArchiveAccountContext works like ArchiveAccount but honors the cancellation and deadline of ctx
+func (ic *internalClient) ArchiveAccountContext(ctx context.Context, id string) error {
+	_, err := ic.doWithAuthContext(ctx, del, "accounts/"+id, nil)
 	return err
 }
 
 // ImportAccount imports an existing account
 func (ic *internalClient) ImportAccount(username, password string, locked bool) (int, error) {
+	return ic.ImportAccountContext(context.Background(), username, password, locked)
+}
+
+// ImportAccountContext works like ImportAccount but honors the cancellation and deadline of ctx
+func (ic *internalClient) ImportAccountContext(ctx context.Context, username, password string, locked bool) (int, error) {
 	form := url.Values{}
 	form.Add("username", username)
 	form.Add("password", password)
 	form.Add("locked", strconv.FormatBool(locked))
 
-	resp, err := ic.doWithAuth(post, "accounts/import", strings.NewReader(form.Encode()))
+	resp, err := ic.doWithAuthContext(ctx, post, "accounts/import", strings.NewReader(form.Encode()))
 	if err != nil {
 		return -1, err
 	}
@@ -151,18 +210,33 @@ func (ic *internalClient) ImportAccount(username, password string, locked bool)
 
 // ExpirePassword expires the users current sessions and flags the account for a required password change on next login
 func (ic *internalClient) ExpirePassword(id string) error {
-	_, err := ic.doWithAuth(patch, "accounts/"+id+"/expire_password", nil)
+	return ic.ExpirePasswordContext(context.Background(), id)
+}
+
+// ExpirePasswordContext works like ExpirePassword but honors the cancellation and deadline of ctx
+func (ic *internalClient) ExpirePasswordContext(ctx context.Context, id string) error {
+	_, err := ic.doWithAuthContext(ctx, patch, "accounts/"+id+"/expire_password", nil)
 	return err
 }
 
 // ServiceStats returns the raw request from the /stats endpoint
 func (ic *internalClient) ServiceStats() (*http.Response, error) {
-	return ic.doWithAuth(get, "stats", nil)
+	return ic.ServiceStatsContext(context.Background())
+}
+
+// ServiceStatsContext works like ServiceStats but honors the cancellation and deadline of ctx
+func (ic *internalClient) ServiceStatsContext(ctx context.Context) (*http.Response, error) {
+	return ic.doWithAuthContext(ctx, get, "stats", nil)
 }
 
 // ServerStats returns the raw request from the /metrics endpoint
 func (ic *internalClient) ServerStats() (*http.Response, error) {
-	return ic.doWithAuth(get, "metrics", nil)
+	return ic.ServerStatsContext(context.Background())
+}
+
+// ServerStatsContext works like ServerStats but honors the cancellation and deadline of ctx
+func (ic *internalClient) ServerStatsContext(ctx context.Context) (*http.Response, error) {
+	return ic.doWithAuthContext(ctx, get, "metrics", nil)
 }
 
 func (ic *internalClient) absoluteURL(path string) string {
@@ -190,8 +264,8 @@ func (ic *internalClient) get(path string, dest interface{}) (int, error) {
 	return resp.StatusCode, nil
 }
 
-func (ic *internalClient) doWithAuth(verb string, path string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(verb, ic.absoluteURL(path), body)
+func (ic *internalClient) doWithAuthContext(ctx context.Context, verb string, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, verb, ic.absoluteURL(path), body)
 	if err != nil {
 		return nil, err
 	}
@@ -206,19 +280,28 @@ func (ic *internalClient) doWithAuth(verb string, path string, body io.Reader) (
 		return nil, err
 	}
 	if !isStatusSuccess(resp.StatusCode) {
-		// try to parse the error response
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			return nil, fmt.Errorf("received %d from %s", resp.StatusCode, ic.absoluteURL(path))
-		}
-
-		errResp.StatusCode = resp.StatusCode
-		errResp.URL = ic.absoluteURL(path)
-		return nil, &errResp
+		return nil, responseError(resp, ic.absoluteURL(path))
 	}
 	return resp, nil
 }
 
+// responseError returns the *ErrorResponse described by the body of an
+// unsuccessful response, or a plain error if the body is no such description.
+// It closes the body.
+func responseError(resp *http.Response, url string) error {
+	defer resp.Body.Close()
+
+	// try to parse the error response
+	var errResp ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		return fmt.Errorf("received %d from %s", resp.StatusCode, url)
+	}
+
+	errResp.StatusCode = resp.StatusCode
+	errResp.URL = url
+	return &errResp
+}
+
 func isStatusSuccess(statusCode int) bool {
 	return statusCode >= 200 && statusCode < 300
 }
@@ -2,11 +2,13 @@ package authn
 
 import (
 	"context"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -142,6 +144,68 @@ func TestICGetAccount(t *testing.T) {
 	}
 }
 
+func TestICGetAccountDetails(t *testing.T) {
+	lastLoginAt := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		times    string
+		expected *Account
+	}{
+		{
+			name:  "times",
+			times: `"last_login_at": "2020-01-02T03:04:05Z", "password_changed_at": null`,
+			expected: &Account{
+				ID:       1,
+				Username: "test@test.com",
+				OAuthAccounts: []OAuthAccount{
+					{Provider: "google", ProviderAccountID: "1234", Email: "test@gmail.com"},
+				},
+				LastLoginAt: &lastLoginAt,
+			},
+		},
+		{
+			name:  "unset times",
+			times: `"last_login_at": "", "password_changed_at": ""`,
+			expected: &Account{
+				ID:       1,
+				Username: "test@test.com",
+				OAuthAccounts: []OAuthAccount{
+					{Provider: "google", ProviderAccountID: "1234", Email: "test@gmail.com"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{
+					"result": {
+						"id": 1,
+						"username": "test@test.com",
+						"oauth_accounts": [
+							{"provider": "google", "provider_account_id": "1234", "email": "test@gmail.com"}
+						],
+						` + tc.times + `,
+						"locked": false,
+						"deleted": false
+					}
+				}`))
+			})
+			httpClient, teardown := testingHTTPClient(h)
+			defer teardown()
+
+			cli, err := newInternalClient("http://test.com", "username", "password")
+			require.NoError(t, err)
+			cli.client = httpClient
+
+			account, err := cli.GetAccount("1")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, account)
+		})
+	}
+}
+
 // Based on information at https://keratin.github.io/authn-server/#/api?id=update
 func TestICUpdate(t *testing.T) {
 	type request struct {
@@ -805,3 +869,60 @@ func TestICErrorResponses(t *testing.T) {
 		}
 	}
 }
+
+func TestICKeySet(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/jwks", r.URL.Path)
+		_, _ = w.Write([]byte(`{"keys": [
+			{"kty": "RSA", "kid": "kid1", "use": "sig", "alg": "RS256", "e": "AQAB", "n": "sXchDaQebHnPiGvyDOAT4saGEUetSyo9MKLOoWFsueri23bOdgWp4Dy1WlUzewbgBHod5pcM9H95GQRV3JDXboIRROSBigeC5yjU1hGzHHyXss8UDprecbAYxknTcQkhslANGRUZmdTOQ5qTRsLAt6BTYuyvVRdhS8exSZEy_c4gs_7svlJJQ4H9_NxsiIoLwAEk7-Q3UXERGYw_75IDrGA84-lA_-Ct4eTlXHBIY2EaV7t7LjJaynVJCpkv4LKjTTAumiGUIuQhrNhZLuF_RJLqHpM2kgWFLU7-VTdL1VbC2tejvcI2BlMkEpk1BzBZI0KQB0GaDWFLN-aEAw3vRw"}
+		]}`))
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	cli, err := newInternalClient("http://test.com", "username", "password")
+	require.NoError(t, err)
+	cli.client = httpClient
+
+	jwks, err := cli.KeySet(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "kid1", jwks.Keys[0].KeyID)
+
+	keys, err := cli.Key("kid1")
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	keys, err = cli.Key("kid2")
+	require.NoError(t, err)
+	assert.Len(t, keys, 0)
+}
+
+func TestICContextCancellation(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	cli, err := newInternalClient("http://test.com", "username", "password")
+	require.NoError(t, err)
+	cli.client = httpClient
+
+	t.Run("admin actions", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := cli.GetAccountContext(ctx, "1")
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("jwks", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := cli.KeyContext(ctx, "kid")
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
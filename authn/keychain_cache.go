@@ -1,43 +1,346 @@
 package authn
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	jose "github.com/go-jose/go-jose/v3"
-	"github.com/patrickmn/go-cache"
 )
 
-// keychainCache is a JWKProvider which wraps around another JWKProvider
-// and adds a caching layer in between
+// keychainCache is a JWKProvider which wraps around a jwkSetProvider
+// and adds a caching layer in between. It caches the complete key set
+// with a single expiry, so keys introduced by a key rotation are picked
+// up together with the first token that uses them.
 type keychainCache struct {
-	keyCache    *cache.Cache //local in-memory cache to store keys
-	keyProvider JWKProvider  //base JWKProvider for backup after cache miss
+	keySet atomic.Value //the cached *cachedKeySet, read without locking
+
+	mu              sync.Mutex           //guards the fields below
+	lastFetch       time.Time            //time of the last attempt to fetch the key set
+	lastErr         error                //error of the last attempt to fetch the key set
+	inflight        *keySetFetch         //the fetch in progress, if any
+	misses          map[string]time.Time //expiry of key IDs known to be missing from the key set
+	ttl             time.Duration
+	refreshInterval time.Duration //minimum time between fetches caused by unknown key IDs
+	missTTL         time.Duration
+	missLimit       int
+	maxStaleness    time.Duration //how long expired keys are still served while refreshes fail
+	onRefreshError  func(error)
+	snapshotPath    string        //file to persist fetched key sets in, if any
+	snapshotMaxAge  time.Duration //age after which a persisted key set is ignored
+	clock           Clock
+	keyProvider     jwkSetProvider //base provider for backup after cache miss
+	stop            chan struct{}  //closed to stop the background refresher, if any
+	stopOnce        sync.Once
+}
+
+// cachedKeySet is an immutable snapshot of a fetched key set
+type cachedKeySet struct {
+	byKeyID    map[string][]jose.JSONWebKey
+	expiresAt  time.Time
+	staleUntil time.Time //keys are served until then if refreshes fail
+}
+
+func newCachedKeySet(jwks *jose.JSONWebKeySet, expiresAt, staleUntil time.Time) *cachedKeySet {
+	byKeyID := map[string][]jose.JSONWebKey{}
+	for _, key := range jwks.Keys {
+		byKeyID[key.KeyID] = append(byKeyID[key.KeyID], key)
+	}
+	return &cachedKeySet{
+		byKeyID:    byKeyID,
+		expiresAt:  expiresAt,
+		staleUntil: staleUntil,
+	}
+}
+
+// keySetFetch is a fetch of the key set shared by all concurrent callers
+type keySetFetch struct {
+	done   chan struct{} //closed when result and err are set
+	result *cachedKeySet
+	err    error
+	kids   map[string]bool //key IDs the callers look for
 }
 
 // Creates a new keychainCache which wraps around keyProvider
-func newKeychainCache(ttl time.Duration, keyProvider JWKProvider) *keychainCache {
-	return &keychainCache{
-		keyCache:    cache.New(ttl, 2*ttl),
-		keyProvider: keyProvider,
+func newKeychainCache(config Config, keyProvider jwkSetProvider) *keychainCache {
+	k := &keychainCache{
+		misses:          map[string]time.Time{},
+		ttl:             time.Duration(config.KeychainTTL) * time.Minute,
+		refreshInterval: config.KeyRefreshInterval,
+		missTTL:         config.NegativeCacheTTL,
+		missLimit:       config.NegativeCacheSize,
+		maxStaleness:    config.MaxKeyStaleness,
+		onRefreshError:  config.OnKeyRefreshError,
+		snapshotPath:    config.KeySnapshotPath,
+		snapshotMaxAge:  config.KeySnapshotMaxAge,
+		clock:           config.Clock,
+		keyProvider:     keyProvider,
 	}
+	k.keySet.Store(newCachedKeySet(&jose.JSONWebKeySet{}, time.Time{}, time.Time{}))
+	return k
+}
+
+// loadSnapshot seeds the cache with the key set persisted at snapshotPath, unless
+// it is older than snapshotMaxAge. Its keys are used like fresh ones until they
+// expire, and afterwards until snapshotMaxAge while refreshes fail.
+func (k *keychainCache) loadSnapshot() {
+	if k.snapshotPath == "" {
+		return
+	}
+	snapshot, err := readKeychainSnapshot(k.snapshotPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			k.reportError(fmt.Errorf("reading key snapshot: %v", err))
+		}
+		return
+	}
+
+	staleUntil := snapshot.FetchedAt.Add(k.snapshotMaxAge)
+	if !k.clock.Now().Before(staleUntil) {
+		return
+	}
+	expiresAt := snapshot.FetchedAt.Add(k.ttl)
+	if expiresAt.After(staleUntil) {
+		expiresAt = staleUntil
+	}
+	k.keySet.Store(newCachedKeySet(&snapshot.JWKS, expiresAt, staleUntil))
+}
+
+// start refreshes the key set in the background until close is called.
+// Refreshes are scheduled ahead of expiry so requests never wait for them.
+func (k *keychainCache) start() {
+	k.stop = make(chan struct{})
+	go k.refreshLoop(k.stop)
+}
+
+// close stops the background refresher, if any
+func (k *keychainCache) close() {
+	k.stopOnce.Do(func() {
+		if k.stop != nil {
+			close(k.stop)
+		}
+	})
+}
+
+func (k *keychainCache) refreshLoop(stop <-chan struct{}) {
+	for {
+		timer := time.NewTimer(k.untilRefresh())
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		_, _ = k.refresh(ctx, k.clock.Now())
+		cancel()
+	}
+}
+
+// untilRefresh returns the time until the background refresher should
+// refresh the key set: a fifth of the TTL ahead of its expiry, or after
+// refreshInterval if the last refresh failed
+func (k *keychainCache) untilRefresh() time.Duration {
+	k.mu.Lock()
+	failed := k.lastErr != nil
+	k.mu.Unlock()
+	if failed {
+		return k.refreshInterval
+	}
+
+	wait := k.cached().expiresAt.Add(-k.ttl / 5).Sub(k.clock.Now())
+	if wait < 0 {
+		return 0
+	}
+	return wait
 }
 
 // Key tries to get signing key from cache. On cache miss it tries to get and cache
 // the signing key from the keyProvider
 func (k *keychainCache) Key(kid string) ([]jose.JSONWebKey, error) {
-	// TODO: Log critical errors
-	if jwks, ok := k.keyCache.Get(kid); ok {
-		return jwks.([]jose.JSONWebKey), nil
+	return k.KeyContext(context.Background(), kid)
+}
+
+// KeyContext works like Key but stops waiting for the keyProvider when ctx is done.
+//
+// Expired key sets are refreshed right away unless the last refresh failed. For up
+// to maxStaleness, expired keys are served while they are refreshed in the background.
+// Unknown key IDs join the refresh in progress, if any, and otherwise only cause a
+// refresh if the last one is at least refreshInterval ago. Key IDs which were missing from a freshly fetched key set are remembered
+// for missTTL, so that garbage key IDs cannot flood the keyProvider with requests.
+func (k *keychainCache) KeyContext(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	cached := k.cached()
+	now := k.clock.Now()
+	keys, known := cached.byKeyID[kid]
+	expired := !now.Before(cached.expiresAt)
+	if known && !expired {
+		return keys, nil
+	}
+
+	k.mu.Lock()
+	if expiresAt, missing := k.misses[kid]; !expired && missing && now.Before(expiresAt) {
+		k.mu.Unlock()
+		return []jose.JSONWebKey{}, nil
+	}
+	if k.inflight == nil && (!expired || k.lastErr != nil) && now.Before(k.lastFetch.Add(k.refreshInterval)) {
+		// report the outcome of the last refresh
+		err := k.lastErr
+		k.mu.Unlock()
+		if err != nil && known && now.Before(cached.staleUntil) {
+			return keys, nil
+		}
+		return []jose.JSONWebKey{}, err
+	}
+	if known && now.Before(cached.staleUntil) {
+		// serve the expired keys instead of waiting for a possibly unreachable keyProvider
+		k.startFetch(now)
+		k.mu.Unlock()
+		return keys, nil
 	}
+	if err := ctx.Err(); err != nil {
+		k.mu.Unlock()
+		return []jose.JSONWebKey{}, err
+	}
+	f := k.startFetch(now)
+	f.kids[kid] = true
+	k.mu.Unlock()
 
-	newjwks, err := k.keyProvider.Key(kid)
+	fresh, err := f.wait(ctx)
 	if err != nil {
 		return []jose.JSONWebKey{}, err
 	}
 
-	if len(newjwks) > 0 {
-		// Only cache if the base provider has keys
-		k.keyCache.Set(kid, newjwks, cache.DefaultExpiration)
+	if keys, ok := fresh.byKeyID[kid]; ok {
+		return keys, nil
+	}
+	return []jose.JSONWebKey{}, nil
+}
+
+// refresh fetches the key set from the keyProvider and caches it, joining the
+// fetch in progress if there is one
+func (k *keychainCache) refresh(ctx context.Context, now time.Time) (*cachedKeySet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	f := k.startFetch(now)
+	k.mu.Unlock()
+	return f.wait(ctx)
+}
+
+// wait returns the result of f, or gives up when ctx is done
+func (f *keySetFetch) wait(ctx context.Context) (*cachedKeySet, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startFetch returns the fetch in progress, starting one if there is none.
+// Concurrent callers share a single fetch, which counts against refreshInterval
+// and runs to completion even if all callers give up, so that its result is
+// cached and the key IDs in its kids are remembered if missing. Failures are
+// reported to onRefreshError. It must be called with k.mu held.
+func (k *keychainCache) startFetch(now time.Time) *keySetFetch {
+	if k.inflight == nil {
+		k.inflight = &keySetFetch{done: make(chan struct{}), kids: map[string]bool{}}
+		k.lastFetch = now
+		go k.fetch(k.inflight, now)
+	}
+	return k.inflight
+}
+
+// fetch performs f and caches its result
+func (k *keychainCache) fetch(f *keySetFetch, now time.Time) {
+	defer close(f.done)
+
+	jwks, err := k.keyProvider.KeySet(context.Background())
+
+	k.mu.Lock()
+	k.inflight = nil
+	if err != nil {
+		f.err = err
+		k.lastErr = err
+		k.mu.Unlock()
+		k.reportError(err)
+		return
+	}
+	k.lastErr = nil
+	f.result = k.store(jwks, now)
+	for kid := range f.kids {
+		if _, ok := f.result.byKeyID[kid]; !ok {
+			k.miss(kid, now)
+		}
+	}
+	k.mu.Unlock()
+
+	if k.snapshotPath != "" {
+		err = writeKeychainSnapshot(k.snapshotPath, &keychainSnapshot{FetchedAt: now, JWKS: *jwks})
+		if err != nil {
+			k.reportError(fmt.Errorf("writing key snapshot: %v", err))
+		}
+	}
+}
+
+// reportError passes err to onRefreshError, if configured
+func (k *keychainCache) reportError(err error) {
+	if k.onRefreshError != nil {
+		k.onRefreshError(err)
+	}
+}
+
+// cached returns the current snapshot of the key set
+func (k *keychainCache) cached() *cachedKeySet {
+	return k.keySet.Load().(*cachedKeySet)
+}
+
+// store replaces the cached key set with jwks and forgets about missing
+// key IDs which are part of it. It must be called with k.mu held.
+func (k *keychainCache) store(jwks *jose.JSONWebKeySet, now time.Time) *cachedKeySet {
+	expiresAt := now.Add(k.ttl)
+	cached := newCachedKeySet(jwks, expiresAt, expiresAt.Add(k.maxStaleness))
+	for kid := range cached.byKeyID {
+		delete(k.misses, kid)
+	}
+	k.keySet.Store(cached)
+	return cached
+}
+
+// miss remembers that kid is missing from the key set. When the negative cache
+// is full, expired entries are dropped first and the oldest entry after that.
+// It must be called with k.mu held.
+func (k *keychainCache) miss(kid string, now time.Time) {
+	if k.missLimit <= 0 {
+		return
+	}
+	if _, ok := k.misses[kid]; !ok && len(k.misses) >= k.missLimit {
+		var oldest string
+		for missed, expiresAt := range k.misses {
+			if !now.Before(expiresAt) {
+				delete(k.misses, missed)
+				continue
+			}
+			if oldest == "" || expiresAt.Before(k.misses[oldest]) {
+				oldest = missed
+			}
+		}
+		if len(k.misses) >= k.missLimit {
+			delete(k.misses, oldest)
+		}
 	}
-	return newjwks, nil
+	k.misses[kid] = now.Add(k.missTTL)
 }
@@ -1,20 +1,45 @@
 package authn
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
 	jose "github.com/go-jose/go-jose/v3"
-	"github.com/patrickmn/go-cache"
+	jwt "github.com/go-jose/go-jose/v3/jwt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// Mock Clock for tests
+// Only moves when told to
+type mockClock struct {
+	now time.Time
+}
+
+func newMockClock() *mockClock {
+	return &mockClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *mockClock) Now() time.Time {
+	return c.now
+}
+
+func (c *mockClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
 // Mock JWKProvider for tests
 // Stores keys locally
 type mockJwkProvider struct {
+	mu        sync.Mutex
 	key_map   map[string]jose.JSONWebKey
 	hit_count int
+	set_err   error //returned by KeySet if set
 }
 
 func newMockJwkProvider() *mockJwkProvider {
@@ -33,6 +58,8 @@ func newMockJwkProvider() *mockJwkProvider {
 }
 
 func (m *mockJwkProvider) Key(kid string) ([]jose.JSONWebKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.hit_count = m.hit_count + 1
 
 	if kid == "kidError" {
@@ -45,9 +72,108 @@ func (m *mockJwkProvider) Key(kid string) ([]jose.JSONWebKey, error) {
 	return []jose.JSONWebKey{}, nil
 }
 
+func (m *mockJwkProvider) KeySet(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hit_count = m.hit_count + 1
+
+	if m.set_err != nil {
+		return nil, m.set_err
+	}
+
+	jwks := &jose.JSONWebKeySet{}
+	for _, jwk := range m.key_map {
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+// hits returns hit_count in a way that is safe for concurrent use
+func (m *mockJwkProvider) hits() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hit_count
+}
+
+// RSA key for signing tokens in tests
+// Generating keys is slow, so each key ID is only generated once
+type testKey struct {
+	kid    string
+	key    *rsa.PrivateKey
+	signer jose.Signer
+}
+
+var testKeys = struct {
+	sync.Mutex
+	byKeyID map[string]*testKey
+}{byKeyID: map[string]*testKey{}}
+
+func newTestKey(t *testing.T, kid string) *testKey {
+	testKeys.Lock()
+	defer testKeys.Unlock()
+	if k, ok := testKeys.byKeyID[kid]; ok {
+		return k
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	k := (&testKey{key: key}).withKeyID(t, kid)
+	testKeys.byKeyID[kid] = k
+	return k
+}
+
+// withKeyID returns a copy of k which signs tokens claiming the given key ID
+func (k *testKey) withKeyID(t *testing.T, kid string) *testKey {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: k.key, KeyID: kid}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	require.NoError(t, err)
+	return &testKey{kid: kid, key: k.key, signer: signer}
+}
+
+// jwk returns the public key as AuthN publishes it
+func (k *testKey) jwk() jose.JSONWebKey {
+	return jose.JSONWebKey{Key: k.key.Public(), KeyID: k.kid}
+}
+
+// provider returns a mockJwkProvider serving the public key
+func (k *testKey) provider() *mockJwkProvider {
+	return &mockJwkProvider{key_map: map[string]jose.JSONWebKey{k.kid: k.jwk()}}
+}
+
+// sign returns a token with the merged claims
+func (k *testKey) sign(t *testing.T, claims ...interface{}) string {
+	builder := jwt.Signed(k.signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+	token, err := builder.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+// testClaims returns the claims of a token issued by https://authn.example.com
+// for app.example.com at now and valid for an hour
+func testClaims(now time.Time) jwt.Claims {
+	return jwt.Claims{
+		Issuer:   "https://authn.example.com",
+		Audience: jwt.Audience{"app.example.com"},
+		Subject:  "42",
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+		IssuedAt: jwt.NewNumericDate(now),
+	}
+}
+
+func newTestKeychainCache(provider jwkSetProvider, clock Clock) *keychainCache {
+	config := Config{KeychainTTL: 1, Clock: clock}
+	config.setDefaults()
+	return newKeychainCache(config, provider)
+}
+
 func TestKeychainCacheHit(t *testing.T) {
 	mock_provider := newMockJwkProvider()
-	keychain_cache := newKeychainCache(time.Minute, mock_provider)
+	keychain_cache := newTestKeychainCache(mock_provider, newMockClock())
 
 	keys1, err := keychain_cache.Key("kid1")
 	assert.NoError(t, err)
@@ -68,12 +194,13 @@ func TestKeychainCacheHit(t *testing.T) {
 	assert.Len(t, keys2, 1)
 	assert.Equal(t, "kid2", keys2[0].KeyID)
 	assert.Equal(t, "test_key2", keys2[0].Key)
-	assert.Equal(t, 2, mock_provider.hit_count) //Because key2 wasnt cached
+	assert.Equal(t, 1, mock_provider.hit_count) //Because key2 came with the same key set
 }
 
 func TestKeychainCacheMissing(t *testing.T) {
 	mock_provider := newMockJwkProvider()
-	keychain_cache := newKeychainCache(time.Minute, mock_provider)
+	clock := newMockClock()
+	keychain_cache := newTestKeychainCache(mock_provider, clock)
 
 	keysNone, err := keychain_cache.Key("kidNone")
 	assert.NoError(t, err)
@@ -83,26 +210,109 @@ func TestKeychainCacheMissing(t *testing.T) {
 	keysNone_again, err := keychain_cache.Key("kidNone")
 	assert.NoError(t, err)
 	assert.Len(t, keysNone_again, 0)
-	assert.Equal(t, 2, mock_provider.hit_count) //Because missing keys are not cached
+	assert.Equal(t, 1, mock_provider.hit_count) //Because refreshes are rate limited
+
+	clock.Add(DefaultKeyRefreshInterval)
+	keysNone_again, err = keychain_cache.Key("kidNone")
+	assert.NoError(t, err)
+	assert.Len(t, keysNone_again, 0)
+	assert.Equal(t, 1, mock_provider.hit_count) //Because kidNone was missing from a fresh key set
+
+	clock.Add(DefaultNegativeCacheTTL)
+	keysNone_again, err = keychain_cache.Key("kidNone")
+	assert.NoError(t, err)
+	assert.Len(t, keysNone_again, 0)
+	assert.Equal(t, 2, mock_provider.hit_count) //Because the negative cache expired
+}
+
+func TestKeychainCacheNegativeCacheLimit(t *testing.T) {
+	mock_provider := newMockJwkProvider()
+	clock := newMockClock()
+	config := Config{KeychainTTL: 1, Clock: clock, NegativeCacheSize: 2}
+	config.setDefaults()
+	keychain_cache := newKeychainCache(config, mock_provider)
+
+	for _, kid := range []string{"kidNone1", "kidNone2", "kidNone3"} {
+		clock.Add(DefaultKeyRefreshInterval)
+		_, err := keychain_cache.Key(kid)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, mock_provider.hit_count)
+	assert.Len(t, keychain_cache.misses, 2)
+
+	clock.Add(DefaultKeyRefreshInterval)
+	_, err := keychain_cache.Key("kidNone3")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, mock_provider.hit_count) //Because kidNone3 is still remembered
+
+	_, err = keychain_cache.Key("kidNone1")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, mock_provider.hit_count) //Because kidNone1 was evicted as the oldest entry
+}
+
+func TestKeychainCacheNegativeCacheCleared(t *testing.T) {
+	mock_provider := newMockJwkProvider()
+	clock := newMockClock()
+	keychain_cache := newTestKeychainCache(mock_provider, clock)
+
+	keys3, err := keychain_cache.Key("kid3")
+	assert.NoError(t, err)
+	assert.Len(t, keys3, 0)
+
+	// kid3 appears and the key set is refreshed for another reason
+	mock_provider.key_map["kid3"] = jose.JSONWebKey{KeyID: "kid3", Key: "test_key3"}
+	clock.Add(time.Minute)
+	_, err = keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, mock_provider.hit_count)
+
+	keys3, err = keychain_cache.Key("kid3")
+	assert.NoError(t, err)
+	assert.Len(t, keys3, 1)
+	assert.Equal(t, 2, mock_provider.hit_count)
+	assert.Len(t, keychain_cache.misses, 0)
+}
+
+func TestKeychainCacheRotation(t *testing.T) {
+	mock_provider := newMockJwkProvider()
+	clock := newMockClock()
+	keychain_cache := newTestKeychainCache(mock_provider, clock)
+
+	_, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mock_provider.hit_count)
+
+	// AuthN rotates its key
+	mock_provider.key_map["kid3"] = jose.JSONWebKey{KeyID: "kid3", Key: "test_key3"}
+	clock.Add(DefaultKeyRefreshInterval)
+
+	keys3, err := keychain_cache.Key("kid3")
+	assert.NoError(t, err)
+	assert.Len(t, keys3, 1)
+	assert.Equal(t, "test_key3", keys3[0].Key)
+	assert.Equal(t, 2, mock_provider.hit_count) //Because kid3 was unseen
+
+	keys1, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Len(t, keys1, 1)
+	assert.Equal(t, 2, mock_provider.hit_count) //Because kid1 came with the same key set
 }
 
 func TestKeychainCacheTTL(t *testing.T) {
 	mock_provider := newMockJwkProvider()
-	keychain_cache := newKeychainCache(time.Minute, mock_provider)
-	// Minimum TTL is 1 min. But we cant wait that long to test.
-	// TODO: Go is not flexible enough to accept both decimal and integer ttl. Consider using seconds?
-	// Hacky test because we are screwing with internals
-	keychain_cache.keyCache = cache.New(time.Second, time.Second)
+	clock := newMockClock()
+	keychain_cache := newTestKeychainCache(mock_provider, clock)
 
 	_, err := keychain_cache.Key("kid1")
 	assert.NoError(t, err)
 	assert.Equal(t, 1, mock_provider.hit_count)
+	clock.Add(59 * time.Second)
 	_, err = keychain_cache.Key("kid1")
 	assert.NoError(t, err)
-	assert.Equal(t, 1, mock_provider.hit_count) //Because we cached itached
+	assert.Equal(t, 1, mock_provider.hit_count) //Because we cached it
 
-	// Wait for cache to expire
-	time.Sleep(time.Second)
+	// Let the cache expire
+	clock.Add(time.Second)
 	keys1, err := keychain_cache.Key("kid1")
 	assert.NoError(t, err)
 	// Assert values post-expiry
@@ -114,15 +324,339 @@ func TestKeychainCacheTTL(t *testing.T) {
 
 func TestKeychainCacheError(t *testing.T) {
 	mock_provider := newMockJwkProvider()
-	keychain_cache := newKeychainCache(time.Minute, mock_provider)
+	mock_provider.set_err = errors.New("testing error")
+	clock := newMockClock()
+	keychain_cache := newTestKeychainCache(mock_provider, clock)
 
-	keysError, err := keychain_cache.Key("kidError")
+	keysError, err := keychain_cache.Key("kid1")
 	assert.EqualError(t, err, "testing error")
 	assert.Len(t, keysError, 0)
 	assert.Equal(t, 1, mock_provider.hit_count)
 
-	keysError_again, err := keychain_cache.Key("kidError")
+	keysError_again, err := keychain_cache.Key("kid1")
 	assert.EqualError(t, err, "testing error")
 	assert.Len(t, keysError_again, 0)
+	assert.Equal(t, 1, mock_provider.hit_count) //Because the failed refresh counts against the interval
+
+	clock.Add(DefaultKeyRefreshInterval)
+	mock_provider.set_err = nil
+	keys1, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Len(t, keys1, 1)
 	assert.Equal(t, 2, mock_provider.hit_count) //Because keys are not cached in case of error
 }
+
+func TestKeychainCacheExpiredError(t *testing.T) {
+	mock_provider := newMockJwkProvider()
+	clock := newMockClock()
+	keychain_cache := newTestKeychainCache(mock_provider, clock)
+
+	_, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mock_provider.hit_count)
+
+	// AuthN goes down after the keys expired
+	mock_provider.set_err = errors.New("testing error")
+	clock.Add(time.Minute)
+	_, err = keychain_cache.Key("kid1")
+	assert.EqualError(t, err, "testing error")
+	assert.Equal(t, 2, mock_provider.hit_count)
+
+	_, err = keychain_cache.Key("kid1")
+	assert.EqualError(t, err, "testing error")
+	assert.Equal(t, 2, mock_provider.hit_count) //Because the failed refresh counts against the interval
+
+	clock.Add(DefaultKeyRefreshInterval)
+	mock_provider.set_err = nil
+	keys1, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Len(t, keys1, 1)
+	assert.Equal(t, 3, mock_provider.hit_count)
+}
+
+func TestKeychainCacheCanceled(t *testing.T) {
+	mock_provider := newMockJwkProvider()
+	keychain_cache := newTestKeychainCache(mock_provider, newMockClock())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := keychain_cache.KeyContext(ctx, "kid1")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, mock_provider.hit_count)
+
+	keys1, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Len(t, keys1, 1)
+	assert.Equal(t, 1, mock_provider.hit_count) //Because no refresh was attempted
+}
+
+// Blocking jwkSetProvider for tests
+// Answers KeySet calls only when released
+type blockingJwkProvider struct {
+	mock     *mockJwkProvider
+	started  chan struct{} //receives once per KeySet call
+	release  chan struct{}
+	canceled chan struct{} //receives if a KeySet call was aborted
+}
+
+func newBlockingJwkProvider() *blockingJwkProvider {
+	return &blockingJwkProvider{
+		mock:     newMockJwkProvider(),
+		started:  make(chan struct{}, 10),
+		release:  make(chan struct{}),
+		canceled: make(chan struct{}, 10),
+	}
+}
+
+func (b *blockingJwkProvider) KeySet(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	b.started <- struct{}{}
+	select {
+	case <-b.release:
+		return b.mock.KeySet(ctx)
+	case <-ctx.Done():
+		b.canceled <- struct{}{}
+		return nil, ctx.Err()
+	}
+}
+
+func TestKeychainCacheSingleFlight(t *testing.T) {
+	t.Run("concurrent misses share one fetch", func(t *testing.T) {
+		provider := newBlockingJwkProvider()
+		keychain_cache := newTestKeychainCache(provider, systemClock{})
+
+		var wg sync.WaitGroup
+		results := make(chan []jose.JSONWebKey, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				keys, err := keychain_cache.Key("kid1")
+				assert.NoError(t, err)
+				results <- keys
+			}()
+		}
+
+		<-provider.started
+		// give the other callers time to join the fetch
+		time.Sleep(50 * time.Millisecond)
+		close(provider.release)
+		wg.Wait()
+		close(results)
+
+		for keys := range results {
+			assert.Len(t, keys, 1)
+		}
+		assert.Equal(t, 1, provider.mock.hits())
+	})
+
+	t.Run("concurrent lookups of an unseen key ID share one fetch", func(t *testing.T) {
+		provider := newBlockingJwkProvider()
+		keychain_cache := newTestKeychainCache(provider, systemClock{})
+		keychain_cache.mu.Lock()
+		keychain_cache.store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid1", Key: "test_key1"}}}, time.Now())
+		keychain_cache.mu.Unlock()
+
+		// AuthN rotates its key
+		provider.mock.key_map["kid3"] = jose.JSONWebKey{KeyID: "kid3", Key: "test_key3"}
+		results := make(chan []jose.JSONWebKey, 5)
+		lookup := func() {
+			keys, err := keychain_cache.Key("kid3")
+			assert.NoError(t, err)
+			results <- keys
+		}
+		go lookup()
+		<-provider.started
+		for i := 0; i < 4; i++ {
+			go lookup()
+		}
+
+		// give the other callers time to join the fetch
+		time.Sleep(50 * time.Millisecond)
+		close(provider.release)
+		for i := 0; i < 5; i++ {
+			assert.Len(t, <-results, 1)
+		}
+		assert.Equal(t, 1, provider.mock.hits())
+	})
+
+	t.Run("errors are shared", func(t *testing.T) {
+		provider := newBlockingJwkProvider()
+		provider.mock.set_err = errors.New("testing error")
+		keychain_cache := newTestKeychainCache(provider, systemClock{})
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := keychain_cache.Key("kid1")
+				errs <- err
+			}()
+		}
+
+		<-provider.started
+		time.Sleep(50 * time.Millisecond)
+		close(provider.release)
+		assert.EqualError(t, <-errs, "testing error")
+		assert.EqualError(t, <-errs, "testing error")
+		assert.Equal(t, 1, provider.mock.hits())
+	})
+
+	t.Run("canceled callers do not abort the fetch for others", func(t *testing.T) {
+		provider := newBlockingJwkProvider()
+		keychain_cache := newTestKeychainCache(provider, systemClock{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan error)
+		go func() {
+			_, err := keychain_cache.KeyContext(ctx, "kid1")
+			canceled <- err
+		}()
+		<-provider.started
+
+		patient := make(chan []jose.JSONWebKey)
+		go func() {
+			keys, err := keychain_cache.Key("kid1")
+			assert.NoError(t, err)
+			patient <- keys
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		cancel()
+		assert.Equal(t, context.Canceled, <-canceled)
+
+		close(provider.release)
+		assert.Len(t, <-patient, 1)
+		assert.Len(t, provider.canceled, 0)
+	})
+
+	t.Run("fetch completes when all callers cancel", func(t *testing.T) {
+		provider := newBlockingJwkProvider()
+		keychain_cache := newTestKeychainCache(provider, systemClock{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			_, err := keychain_cache.KeyContext(ctx, "kidNone")
+			done <- err
+		}()
+		<-provider.started
+
+		cancel()
+		assert.Equal(t, context.Canceled, <-done)
+
+		close(provider.release)
+		assert.Eventually(t, func() bool { return len(keychain_cache.cached().byKeyID) > 0 }, time.Second, 10*time.Millisecond)
+		keychain_cache.mu.Lock()
+		assert.Contains(t, keychain_cache.misses, "kidNone")
+		keychain_cache.mu.Unlock()
+
+		keys, err := keychain_cache.Key("kidNone2")
+		assert.NoError(t, err)
+		assert.Len(t, keys, 0)
+		assert.Equal(t, 1, provider.mock.hits()) //Because the abandoned fetch counts against the interval
+		assert.Len(t, provider.canceled, 0)
+	})
+}
+
+func TestKeychainCacheStale(t *testing.T) {
+	mock_provider := newMockJwkProvider()
+	clock := newMockClock()
+	var mu sync.Mutex
+	var reported []error
+	config := Config{
+		KeychainTTL:     1,
+		Clock:           clock,
+		MaxKeyStaleness: time.Hour,
+		OnKeyRefreshError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err)
+		},
+	}
+	config.setDefaults()
+	keychain_cache := newKeychainCache(config, mock_provider)
+	reports := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reported)
+	}
+
+	_, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mock_provider.hits())
+
+	// AuthN goes down after the keys expired
+	mock_provider.mu.Lock()
+	mock_provider.set_err = errors.New("testing error")
+	mock_provider.mu.Unlock()
+	clock.Add(time.Minute)
+	keys1, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Len(t, keys1, 1)
+	assert.Eventually(t, func() bool { return reports() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, mock_provider.hits())
+
+	keys1, err = keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Len(t, keys1, 1)
+	assert.Equal(t, 2, mock_provider.hits()) //Because failed refreshes are rate limited
+
+	// Beyond the maximum staleness
+	clock.Add(time.Hour)
+	_, err = keychain_cache.Key("kid1")
+	assert.EqualError(t, err, "testing error")
+	assert.Equal(t, 3, mock_provider.hits())
+	assert.Equal(t, 2, reports())
+}
+
+func TestKeychainCacheStaleWhileRefreshing(t *testing.T) {
+	provider := newBlockingJwkProvider()
+	clock := newMockClock()
+	config := Config{KeychainTTL: 1, Clock: clock, MaxKeyStaleness: time.Hour}
+	config.setDefaults()
+	keychain_cache := newKeychainCache(config, provider)
+	keychain_cache.mu.Lock()
+	keychain_cache.store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid1", Key: "test_key1"}}}, clock.Now())
+	keychain_cache.mu.Unlock()
+
+	// AuthN hangs after the keys expired
+	clock.Add(time.Minute)
+	done := make(chan []jose.JSONWebKey)
+	go func() {
+		keys, err := keychain_cache.Key("kid1")
+		assert.NoError(t, err)
+		done <- keys
+	}()
+	select {
+	case keys := <-done:
+		assert.Len(t, keys, 1)
+	case <-time.After(time.Second):
+		t.Fatal("expired keys were not served while refreshing")
+	}
+	<-provider.started
+
+	close(provider.release)
+	expiresAt := clock.Now().Add(time.Minute)
+	assert.Eventually(t, func() bool { return keychain_cache.cached().expiresAt.Equal(expiresAt) }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, provider.mock.hits())
+}
+
+func TestKeychainCacheBackgroundRefresh(t *testing.T) {
+	mock_provider := newMockJwkProvider()
+	keychain_cache := newTestKeychainCache(mock_provider, systemClock{})
+	// Minimum TTL is 1 min. But we cant wait that long to test.
+	keychain_cache.ttl = 50 * time.Millisecond
+
+	keychain_cache.start()
+	assert.Eventually(t, func() bool { return mock_provider.hits() >= 3 }, time.Second, 10*time.Millisecond)
+	keychain_cache.close()
+	keychain_cache.close()
+
+	// requests are served from the cache refreshed in the background
+	keys1, err := keychain_cache.Key("kid1")
+	assert.NoError(t, err)
+	assert.Len(t, keys1, 1)
+
+	hits := mock_provider.hits()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, hits, mock_provider.hits()) //Because the refresher stopped
+}
@@ -0,0 +1,65 @@
+package authn
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// keychainSnapshot is the on-disk format of a fetched key set. It lets a
+// fresh process verify tokens before it is able to reach the AuthN server.
+type keychainSnapshot struct {
+	FetchedAt time.Time          `json:"fetched_at"`
+	JWKS      jose.JSONWebKeySet `json:"jwks"`
+}
+
+// readKeychainSnapshot reads the snapshot at path
+func readKeychainSnapshot(path string) (*keychainSnapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &keychainSnapshot{}
+	err = json.Unmarshal(data, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// writeKeychainSnapshot atomically replaces the snapshot at path, so
+// concurrent readers never see a partially written file
+func writeKeychainSnapshot(path string, snapshot *keychainSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// the file only holds public keys
+	err = os.Chmod(tmp.Name(), 0644) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
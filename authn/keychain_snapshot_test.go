@@ -0,0 +1,117 @@
+package authn
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSnapshotTestProvider(t *testing.T) *mockJwkProvider {
+	jwk := newTestKey(t, "kid1").jwk()
+	jwk.Algorithm = "RS256"
+	jwk.Use = "sig"
+	return &mockJwkProvider{key_map: map[string]jose.JSONWebKey{"kid1": jwk}}
+}
+
+func newSnapshotTestKeychainCache(provider jwkSetProvider, clock Clock, path string) *keychainCache {
+	config := Config{KeychainTTL: 1, Clock: clock, KeySnapshotPath: path}
+	config.setDefaults()
+	return newKeychainCache(config, provider)
+}
+
+func TestKeychainSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "authn-go")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+
+	provider := newSnapshotTestProvider(t)
+	clock := newMockClock()
+
+	// a running process persists the fetched keys
+	keychain_cache := newSnapshotTestKeychainCache(provider, clock, path)
+	_, err = keychain_cache.Key("kid1")
+	require.NoError(t, err)
+
+	snapshot, err := readKeychainSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now(), snapshot.FetchedAt.UTC())
+	require.Len(t, snapshot.JWKS.Keys, 1)
+	assert.Equal(t, provider.key_map["kid1"].Key, snapshot.JWKS.Keys[0].Key)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, matches) //Because the temporary file was renamed
+
+	t.Run("cold start", func(t *testing.T) {
+		unreachable := &mockJwkProvider{set_err: errors.New("connection refused")}
+		restarted := newSnapshotTestKeychainCache(unreachable, clock, path)
+		restarted.loadSnapshot()
+
+		keys, err := restarted.Key("kid1")
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+		assert.Equal(t, 0, unreachable.hit_count)
+	})
+
+	t.Run("expired snapshot while AuthN is down", func(t *testing.T) {
+		later := newMockClock()
+		later.Add(time.Hour)
+		unreachable := &mockJwkProvider{set_err: errors.New("connection refused")}
+		restarted := newSnapshotTestKeychainCache(unreachable, later, path)
+		restarted.loadSnapshot()
+
+		keys, err := restarted.Key("kid1")
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+		assert.Eventually(t, func() bool { return unreachable.hits() == 1 }, time.Second, 10*time.Millisecond) //Because the keys expired
+	})
+
+	t.Run("snapshot beyond maximum age", func(t *testing.T) {
+		later := newMockClock()
+		later.Add(DefaultKeySnapshotMaxAge)
+		unreachable := &mockJwkProvider{set_err: errors.New("connection refused")}
+		restarted := newSnapshotTestKeychainCache(unreachable, later, path)
+		restarted.loadSnapshot()
+
+		_, err := restarted.Key("kid1")
+		assert.EqualError(t, err, "connection refused")
+	})
+
+	t.Run("corrupt snapshot", func(t *testing.T) {
+		corrupt := filepath.Join(dir, "corrupt.json")
+		require.NoError(t, ioutil.WriteFile(corrupt, []byte("{"), 0600))
+
+		var reported []error
+		config := Config{KeychainTTL: 1, Clock: clock, KeySnapshotPath: corrupt, OnKeyRefreshError: func(err error) {
+			reported = append(reported, err)
+		}}
+		config.setDefaults()
+		restarted := newKeychainCache(config, provider)
+		restarted.loadSnapshot()
+
+		assert.Len(t, reported, 1)
+		keys, err := restarted.Key("kid1")
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+	})
+
+	t.Run("missing snapshot", func(t *testing.T) {
+		var reported []error
+		config := Config{KeychainTTL: 1, Clock: clock, KeySnapshotPath: filepath.Join(dir, "missing.json"), OnKeyRefreshError: func(err error) {
+			reported = append(reported, err)
+		}}
+		config.setDefaults()
+		restarted := newKeychainCache(config, provider)
+		restarted.loadSnapshot()
+
+		assert.Len(t, reported, 0)
+	})
+}
@@ -0,0 +1,130 @@
+package authn
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type contextKey int
+
+const (
+	claimsContextKey contextKey = iota
+	requestHostContextKey
+)
+
+// MiddlewareOption configures the behavior of Client.Middleware
+type MiddlewareOption func(*middleware)
+
+// Optional lets requests without a valid idToken through to the next
+// handler instead of rejecting them. Such requests carry no claims in
+// their context.
+func Optional() MiddlewareOption {
+	return func(m *middleware) {
+		m.optional = true
+	}
+}
+
+// ExtractTokenWith makes the middleware look for the idToken with
+// extractor instead of BearerTokenExtractor
+func ExtractTokenWith(extractor TokenExtractor) MiddlewareOption {
+	return func(m *middleware) {
+		m.extractor = extractor
+	}
+}
+
+// RespondWith makes the middleware render rejected requests with
+// errorWriter instead of a plain BearerErrorWriter
+func RespondWith(errorWriter ErrorWriter) MiddlewareOption {
+	return func(m *middleware) {
+		m.errorWriter = errorWriter
+	}
+}
+
+// RequireAuthAge makes the middleware reject requests of users who
+// authenticated more than maxAge ago. They are answered according to RFC 9470
+// with an insufficient_user_authentication error and a max_age parameter,
+// so the frontend can ask the user to log in again.
+func RequireAuthAge(maxAge time.Duration) MiddlewareOption {
+	return func(m *middleware) {
+		m.maxAuthAge = maxAge
+	}
+}
+
+type middleware struct {
+	client      *Client
+	optional    bool
+	maxAuthAge  time.Duration
+	extractor   TokenExtractor
+	errorWriter ErrorWriter
+}
+
+// Middleware returns a net/http middleware which verifies the bearer token
+// in the Authorization header of each request with ClaimsFromContext. See
+// ExtractTokenWith to look for the token elsewhere. The verified claims are
+// available to downstream handlers through the package functions
+// ClaimsFromContext and SubjectFromContext.
+//
+// By default requests without a valid token are rejected with
+// 401 Unauthorized and an RFC 6750 WWW-Authenticate header. See Optional
+// to let them through and RespondWith to customize the response.
+func (ac *Client) Middleware(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middleware{
+		client:      ac,
+		extractor:   BearerTokenExtractor(),
+		errorWriter: BearerErrorWriter{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idToken, err := m.extractor.ExtractToken(r)
+			if err != nil {
+				m.reject(next, w, r, err)
+				return
+			}
+
+			claims, err := m.client.ClaimsFromContext(WithRequestHost(r.Context(), r.Host), idToken)
+			if err != nil {
+				m.reject(next, w, r, err)
+				return
+			}
+			if m.maxAuthAge > 0 {
+				err = m.client.CheckAuthAge(claims, m.maxAuthAge)
+				if err != nil {
+					m.reject(next, w, r, err)
+					return
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func (m *middleware) reject(next http.Handler, w http.ResponseWriter, r *http.Request, err error) {
+	if m.optional {
+		next.ServeHTTP(w, r)
+		return
+	}
+	m.errorWriter.WriteError(w, r, err)
+}
+
+// ClaimsFromContext returns the claims stored in ctx by Client.Middleware
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok
+}
+
+// SubjectFromContext returns the subject of the claims stored in ctx by
+// Client.Middleware. This is the AuthN account ID of the authenticated user.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return claims.Subject, true
+}
@@ -0,0 +1,145 @@
+package authn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMiddlewareTestClient returns a Client verifying tokens signed by the
+// returned key, without any HTTP access to an AuthN server
+func newMiddlewareTestClient(t *testing.T) (*Client, *testKey) {
+	key := newTestKey(t, "defaultKey")
+	config := Config{Issuer: "https://authn.example.com", Audience: "app.example.com"}
+	config.setDefaults()
+	verifier, err := NewIDTokenVerifier(config.Issuer, config.Audience, key.provider())
+	require.NoError(t, err)
+
+	return &Client{config: config, verifier: verifier}, key
+}
+
+func signMiddlewareTestToken(t *testing.T, key *testKey, subject string) string {
+	claims := testClaims(time.Now())
+	claims.Subject = subject
+	return key.sign(t, claims)
+}
+
+func TestMiddleware(t *testing.T) {
+	client, key := newMiddlewareTestClient(t)
+	token := signMiddlewareTestToken(t, key, "42")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := SubjectFromContext(r.Context())
+		if !ok {
+			sub = "anonymous"
+		}
+		_, _ = w.Write([]byte(sub))
+	})
+
+	testCases := []struct {
+		name          string
+		opts          []MiddlewareOption
+		authorization string
+		code          int
+		body          string
+	}{
+		{"required with token", nil, "Bearer " + token, http.StatusOK, "42"},
+		{"required with lowercase scheme", nil, "bearer " + token, http.StatusOK, "42"},
+		{"required without token", nil, "", http.StatusUnauthorized, "Unauthorized\n"},
+		{"required with other scheme", nil, "Basic " + token, http.StatusUnauthorized, "Unauthorized\n"},
+		{"required with invalid token", nil, "Bearer a.b.c", http.StatusUnauthorized, "Unauthorized\n"},
+		{"optional with token", []MiddlewareOption{Optional()}, "Bearer " + token, http.StatusOK, "42"},
+		{"optional without token", []MiddlewareOption{Optional()}, "", http.StatusOK, "anonymous"},
+		{"optional with invalid token", []MiddlewareOption{Optional()}, "Bearer a.b.c", http.StatusOK, "anonymous"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			client.Middleware(tc.opts...)(handler).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.body, w.Body.String())
+		})
+	}
+}
+
+func TestMiddlewareExtractTokenWith(t *testing.T) {
+	client, key := newMiddlewareTestClient(t)
+	token := signMiddlewareTestToken(t, key, "42")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := SubjectFromContext(r.Context())
+		_, _ = w.Write([]byte(sub))
+	})
+	mw := client.Middleware(ExtractTokenWith(CookieTokenExtractor("id_token")))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "id_token", Value: token})
+	w := httptest.NewRecorder()
+	mw(handler).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "42", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	mw(handler).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMiddlewareRequireAuthAge(t *testing.T) {
+	client, key := newMiddlewareTestClient(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := SubjectFromContext(r.Context())
+		_, _ = w.Write([]byte(sub))
+	})
+	mw := client.Middleware(RequireAuthAge(5 * time.Minute))
+
+	sign := func(authTime time.Time) string {
+		return key.sign(t, Claims{AuthTime: jwt.NewNumericDate(authTime), Claims: testClaims(time.Now())})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+sign(time.Now().Add(-time.Minute)))
+	w := httptest.NewRecorder()
+	mw(handler).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "42", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+sign(time.Now().Add(-time.Hour)))
+	w = httptest.NewRecorder()
+	mw(handler).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="insufficient_user_authentication", error_description="authentication too old", max_age=300`, w.Header().Get("WWW-Authenticate"))
+
+	// tokens without auth_time cannot prove a recent authentication
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+signMiddlewareTestToken(t, key, "42"))
+	w = httptest.NewRecorder()
+	mw(handler).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestClaimsFromContext(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	claims, ok := ClaimsFromContext(r.Context())
+	assert.False(t, ok)
+	assert.Nil(t, claims)
+
+	sub, ok := SubjectFromContext(r.Context())
+	assert.False(t, ok)
+	assert.Equal(t, "", sub)
+}
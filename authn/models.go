@@ -1,16 +1,75 @@
 package authn
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 )
 
 // Account is an AuthN user account
 type Account struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Locked   bool   `json:"locked"`
-	Deleted  bool   `json:"deleted"`
+	ID                int            `json:"id"`
+	Username          string         `json:"username"`
+	OAuthAccounts     []OAuthAccount `json:"oauth_accounts"`
+	LastLoginAt       *time.Time     `json:"last_login_at"`       // nil if the user never logged in
+	PasswordChangedAt *time.Time     `json:"password_changed_at"` // nil if unknown to the AuthN server
+	Locked            bool           `json:"locked"`
+	Deleted           bool           `json:"deleted"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. AuthN sends unset times as
+// empty strings, which leave LastLoginAt and PasswordChangedAt nil just
+// like null does.
+func (a *Account) UnmarshalJSON(data []byte) error {
+	type account Account
+	raw := struct {
+		*account
+		LastLoginAt       optionalTime `json:"last_login_at"`
+		PasswordChangedAt optionalTime `json:"password_changed_at"`
+	}{account: (*account)(a)}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	a.LastLoginAt = raw.LastLoginAt.time
+	a.PasswordChangedAt = raw.PasswordChangedAt.time
+	return nil
+}
+
+// optionalTime decodes a time which may be null or an empty string
+type optionalTime struct {
+	time *time.Time
+}
+
+func (t *optionalTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" || string(data) == `""` {
+		t.time = nil
+		return nil
+	}
+	var parsed time.Time
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	t.time = &parsed
+	return nil
+}
+
+// OAuthAccount is an identity of an OAuth provider linked to an AuthN account
+type OAuthAccount struct {
+	Provider          string `json:"provider"`
+	ProviderAccountID string `json:"provider_account_id"`
+	Email             string `json:"email"`
+}
+
+// Session is an AuthN session established through the public API
+type Session struct {
+	IDToken string  // the ID token issued for the session
+	Claims  *Claims // the verified claims of IDToken
+
+	// Cookie is AuthN's session cookie, if the response set one. Forward
+	// it to the browser or keep its value to refresh the session later.
+	Cookie *http.Cookie
 }
 
 // FieldError is a returned for each field in an API
@@ -0,0 +1,95 @@
+package authn
+
+import (
+	"context"
+	"fmt"
+
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+)
+
+// TrustedIssuer describes an AuthN deployment whose ID tokens are accepted
+// by a MultiIssuerVerifier
+type TrustedIssuer struct {
+	Issuer   string      //the deployment's issuer, as configured in its AUTHN_URL
+	Audience string      //the audience its tokens must be issued for
+	Keychain JWKProvider //the deployment's signing keys, e.g. Client.KeyProvider or a StaticJWKProvider
+}
+
+// MultiIssuerVerifier verifies ID tokens issued by any of several AuthN
+// deployments. Each token is routed to the keys of its issuer by the
+// unverified iss claim, and then fully verified against that issuer.
+type MultiIssuerVerifier struct {
+	verifiers map[string]*idTokenVerifier
+}
+
+// VerifiedToken holds the verified claims of an ID token together with the
+// trusted issuer which signed it
+type VerifiedToken struct {
+	Issuer string
+	Claims *Claims
+}
+
+// NewMultiIssuerVerifier creates a MultiIssuerVerifier accepting tokens of the
+// given issuers. The options apply to the verification of all of them.
+func NewMultiIssuerVerifier(issuers []TrustedIssuer, opts ...VerifierOption) (*MultiIssuerVerifier, error) {
+	mv := &MultiIssuerVerifier{verifiers: map[string]*idTokenVerifier{}}
+	for _, issuer := range issuers {
+		if issuer.Keychain == nil {
+			return nil, fmt.Errorf("issuer %q has no keychain", issuer.Issuer)
+		}
+		verifier, err := newIDTokenVerifierWithAudiences(issuer.Issuer, jwt.Audience{issuer.Audience}, issuer.Keychain, opts...)
+		if err != nil {
+			return nil, err
+		}
+		iss := verifier.issuerURL.String()
+		if _, ok := mv.verifiers[iss]; ok {
+			return nil, fmt.Errorf("issuer %q is configured twice", iss)
+		}
+		mv.verifiers[iss] = verifier
+	}
+	return mv, nil
+}
+
+// Verify verifies idToken with the keys and expectations of its issuer
+func (mv *MultiIssuerVerifier) Verify(idToken string) (*VerifiedToken, error) {
+	return mv.VerifyContext(context.Background(), idToken)
+}
+
+// VerifyContext works like Verify but honors the cancellation and deadline of
+// ctx while fetching signing keys.
+func (mv *MultiIssuerVerifier) VerifyContext(ctx context.Context, idToken string) (*VerifiedToken, error) {
+	idJwt, err := jwt.ParseSigned(idToken)
+	if err != nil {
+		return nil, newVerificationError(ErrMalformedToken, err)
+	}
+	// the issuer only selects the keys, so it is safe to read it before the signature is verified
+	unverified := jwt.Claims{}
+	err = idJwt.UnsafeClaimsWithoutVerification(&unverified)
+	if err != nil {
+		return nil, newVerificationError(ErrMalformedToken, err)
+	}
+	verifier, ok := mv.verifiers[unverified.Issuer]
+	if !ok {
+		return nil, newVerificationError(ErrInvalidIssuer, fmt.Errorf("issuer %q is not trusted", unverified.Issuer))
+	}
+
+	claims, err := verifier.GetVerifiedClaimsContext(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifiedToken{Issuer: unverified.Issuer, Claims: claims}, nil
+}
+
+// GetVerifiedClaims implements JWTClaimsExtractor
+func (mv *MultiIssuerVerifier) GetVerifiedClaims(idToken string) (*Claims, error) {
+	return mv.GetVerifiedClaimsContext(context.Background(), idToken)
+}
+
+// GetVerifiedClaimsContext implements JWTClaimsExtractorContext
+func (mv *MultiIssuerVerifier) GetVerifiedClaimsContext(ctx context.Context, idToken string) (*Claims, error) {
+	token, err := mv.VerifyContext(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	return token.Claims, nil
+}
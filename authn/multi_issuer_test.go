@@ -0,0 +1,84 @@
+package authn
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	jwt "github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiIssuerVerifier(t *testing.T) {
+	euKey := newTestKey(t, "eu")
+	usKey := newTestKey(t, "us")
+
+	euKeys, err := NewStaticJWKProvider(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{euKey.jwk()}})
+	require.NoError(t, err)
+	usKeys, err := NewStaticJWKProvider(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{usKey.jwk()}})
+	require.NoError(t, err)
+
+	verifier, err := NewMultiIssuerVerifier([]TrustedIssuer{
+		{Issuer: "https://authn.eu.example.com", Audience: "app.example.com", Keychain: euKeys},
+		{Issuer: "https://authn.us.example.com", Audience: "app.example.com", Keychain: usKeys},
+	})
+	require.NoError(t, err)
+
+	sign := func(key *testKey, issuer, audience string) string {
+		claims := testClaims(time.Now())
+		claims.Issuer = issuer
+		claims.Audience = jwt.Audience{audience}
+		return key.sign(t, claims)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		token, err := verifier.Verify(sign(euKey, "https://authn.eu.example.com", "app.example.com"))
+		require.NoError(t, err)
+		assert.Equal(t, "https://authn.eu.example.com", token.Issuer)
+		assert.Equal(t, "42", token.Claims.Subject)
+
+		token, err = verifier.Verify(sign(usKey, "https://authn.us.example.com", "app.example.com"))
+		require.NoError(t, err)
+		assert.Equal(t, "https://authn.us.example.com", token.Issuer)
+
+		claims, err := verifier.GetVerifiedClaims(sign(usKey, "https://authn.us.example.com", "app.example.com"))
+		require.NoError(t, err)
+		assert.Equal(t, "42", claims.Subject)
+	})
+
+	testCases := []struct {
+		name  string
+		token string
+		kind  error
+	}{
+		{"untrusted issuer", sign(euKey, "https://authn.evil.example.com", "app.example.com"), ErrInvalidIssuer},
+		{"key of other issuer", sign(euKey, "https://authn.us.example.com", "app.example.com"), ErrUnknownKey},
+		{"forged key ID", sign(euKey.withKeyID(t, "us"), "https://authn.us.example.com", "app.example.com"), ErrInvalidSignature},
+		{"invalid audience", sign(euKey, "https://authn.eu.example.com", "other.example.com"), ErrInvalidAudience},
+		{"malformed token", "a.b.c", ErrMalformedToken},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := verifier.Verify(tc.token)
+			assert.True(t, errors.Is(err, tc.kind), "expected %v, got %v", tc.kind, err)
+		})
+	}
+}
+
+func TestNewMultiIssuerVerifierErrors(t *testing.T) {
+	keys, err := NewStaticJWKProvider(jose.JSONWebKeySet{})
+	require.NoError(t, err)
+
+	_, err = NewMultiIssuerVerifier([]TrustedIssuer{
+		{Issuer: "https://authn.example.com", Audience: "app.example.com", Keychain: keys},
+		{Issuer: "https://authn.example.com", Audience: "other.example.com", Keychain: keys},
+	})
+	assert.EqualError(t, err, `issuer "https://authn.example.com" is configured twice`)
+
+	_, err = NewMultiIssuerVerifier([]TrustedIssuer{
+		{Issuer: "https://authn.example.com", Audience: "app.example.com"},
+	})
+	assert.EqualError(t, err, `issuer "https://authn.example.com" has no keychain`)
+}
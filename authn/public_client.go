@@ -0,0 +1,177 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// publicClient drives the public API of the AuthN server, which is otherwise
+// used by authn-js in the browser
+type publicClient struct {
+	client     *http.Client
+	baseURL    *url.URL
+	origin     string //sent as Origin header, which AuthN checks against its APP_DOMAINS
+	cookieName string //name of AuthN's session cookie
+}
+
+func newPublicClient(base, origin, cookieName string) (*publicClient, error) {
+	// ensure that base ends with a '/', so ResolveReference() will work as desired
+	if base[len(base)-1] != '/' {
+		base = base + "/"
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return &publicClient{
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		baseURL:    baseURL,
+		origin:     origin,
+		cookieName: cookieName,
+	}, nil
+}
+
+// Signup creates an account and logs it in
+func (pc *publicClient) Signup(ctx context.Context, username, password string) (string, *http.Cookie, error) {
+	form := url.Values{}
+	form.Add("username", username)
+	form.Add("password", password)
+	return pc.idToken(ctx, post, "accounts", form, "")
+}
+
+// Login logs the account with the given credentials in
+func (pc *publicClient) Login(ctx context.Context, username, password string) (string, *http.Cookie, error) {
+	form := url.Values{}
+	form.Add("username", username)
+	form.Add("password", password)
+	return pc.idToken(ctx, post, "session", form, "")
+}
+
+// Refresh returns a new ID token for the given session
+func (pc *publicClient) Refresh(ctx context.Context, session string) (string, *http.Cookie, error) {
+	return pc.idToken(ctx, get, "session/refresh", nil, session)
+}
+
+// Logout ends the given session
+func (pc *publicClient) Logout(ctx context.Context, session string) error {
+	resp, err := pc.do(ctx, del, "session", nil, session)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// RequestPasswordReset makes AuthN send a password reset token for the
+// account with the given username to the application
+func (pc *publicClient) RequestPasswordReset(ctx context.Context, username string) error {
+	return pc.request(ctx, "password/reset", username)
+}
+
+// ResetPassword sets a new password with a password reset token and logs the
+// account in
+func (pc *publicClient) ResetPassword(ctx context.Context, token, password string) (string, *http.Cookie, error) {
+	form := url.Values{}
+	form.Add("token", token)
+	form.Add("password", password)
+	return pc.idToken(ctx, post, "password", form, "")
+}
+
+// RequestPasswordlessLogin makes AuthN send a passwordless login token for
+// the account with the given username to the application
+func (pc *publicClient) RequestPasswordlessLogin(ctx context.Context, username string) error {
+	return pc.request(ctx, "passwordless/token", username)
+}
+
+// PasswordlessLogin logs an account in with a passwordless login token
+func (pc *publicClient) PasswordlessLogin(ctx context.Context, token string) (string, *http.Cookie, error) {
+	form := url.Values{}
+	form.Add("token", token)
+	return pc.idToken(ctx, post, "passwordless/login", form, "")
+}
+
+// request asks AuthN to send a token for the account with the given username
+func (pc *publicClient) request(ctx context.Context, path, username string) error {
+	form := url.Values{}
+	form.Add("username", username)
+	resp, err := pc.do(ctx, get, path, form, "")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// idToken performs a request responding with an ID token and returns it
+// together with the session cookie, if any
+func (pc *publicClient) idToken(ctx context.Context, verb, path string, form url.Values, session string) (string, *http.Cookie, error) {
+	resp, err := pc.do(ctx, verb, path, form, session)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	data := struct {
+		Result struct {
+			IDToken string `json:"id_token"`
+		} `json:"result"`
+	}{}
+
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return "", nil, err
+	}
+	return data.Result.IDToken, pc.sessionCookie(resp), nil
+}
+
+// sessionCookie returns the session cookie set by resp, if any
+func (pc *publicClient) sessionCookie(resp *http.Response) *http.Cookie {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == pc.cookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// do performs a request of the public API. Like HTML forms, form is sent as
+// query of GET requests and as body otherwise.
+func (pc *publicClient) do(ctx context.Context, verb, path string, form url.Values, session string) (*http.Response, error) {
+	target := pc.absoluteURL(path)
+	var body io.Reader
+	if form != nil && verb == get {
+		target += "?" + form.Encode()
+	} else if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, verb, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Origin", pc.origin)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: pc.cookieName, Value: session})
+	}
+
+	resp, err := pc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !isStatusSuccess(resp.StatusCode) {
+		return nil, responseError(resp, pc.absoluteURL(path))
+	}
+	return resp, nil
+}
+
+func (pc *publicClient) absoluteURL(path string) string {
+	return pc.baseURL.ResolveReference(&url.URL{Path: path}).String()
+}
@@ -0,0 +1,149 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Based on information at https://keratin.github.io/authn-server/#/api?id=login
+func TestPCLogin(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/session", r.URL.Path)
+		assert.Equal(t, "https://app.example.com", r.Header.Get("Origin"))
+		_, _, ok := r.BasicAuth()
+		assert.False(t, ok)
+
+		if r.PostFormValue("password") != "secret" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"errors": [{"field": "credentials", "message": "FAILED"}]}`))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "authn", Value: "session-token", HttpOnly: true})
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"result": {"id_token": "id-token"}}`))
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	pc, err := newPublicClient("http://test.com", "https://app.example.com", "authn")
+	require.NoError(t, err)
+	pc.client = httpClient
+
+	idToken, cookie, err := pc.Login(context.Background(), "test@test.com", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "id-token", idToken)
+	require.NotNil(t, cookie)
+	assert.Equal(t, "session-token", cookie.Value)
+
+	_, _, err = pc.Login(context.Background(), "test@test.com", "wrong")
+	var errResp *ErrorResponse
+	require.True(t, errors.As(err, &errResp))
+	assert.Equal(t, http.StatusUnprocessableEntity, errResp.StatusCode)
+	msg, _ := errResp.Field("credentials")
+	assert.Equal(t, "FAILED", msg)
+}
+
+// Based on information at https://keratin.github.io/authn-server/#/api?id=refresh-session
+func TestPCSession(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("custom")
+		if err != nil || cookie.Value != "session-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/session/refresh":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"result": {"id_token": "refreshed"}}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/session":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	pc, err := newPublicClient("http://test.com", "https://app.example.com", "custom")
+	require.NoError(t, err)
+	pc.client = httpClient
+
+	idToken, cookie, err := pc.Refresh(context.Background(), "session-token")
+	require.NoError(t, err)
+	assert.Equal(t, "refreshed", idToken)
+	assert.Nil(t, cookie)
+
+	_, _, err = pc.Refresh(context.Background(), "expired")
+	assert.EqualError(t, err, "received 401 from http://test.com/session/refresh")
+
+	assert.NoError(t, pc.Logout(context.Background(), "session-token"))
+}
+
+// Based on information at https://keratin.github.io/authn-server/#/api?id=request-password-reset
+func TestPCTokenFlows(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "https://app.example.com", r.Header.Get("Origin"))
+		switch {
+		case r.Method == http.MethodGet && (r.URL.Path == "/password/reset" || r.URL.Path == "/passwordless/token"):
+			assert.Equal(t, "test@test.com", r.URL.Query().Get("username"))
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && r.URL.Path == "/password":
+			if r.PostFormValue("token") != "reset-token" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"errors": [{"field": "token", "message": "INVALID_OR_EXPIRED"}]}`))
+				return
+			}
+			if r.PostFormValue("password") == "" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"errors": [{"field": "password", "message": "MISSING"}]}`))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"result": {"id_token": "reset"}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/passwordless/login":
+			if r.PostFormValue("token") != "passwordless-token" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"errors": [{"field": "token", "message": "INVALID_OR_EXPIRED"}]}`))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"result": {"id_token": "passwordless"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	pc, err := newPublicClient("http://test.com", "https://app.example.com", "authn")
+	require.NoError(t, err)
+	pc.client = httpClient
+	ctx := context.Background()
+
+	assert.NoError(t, pc.RequestPasswordReset(ctx, "test@test.com"))
+	assert.NoError(t, pc.RequestPasswordlessLogin(ctx, "test@test.com"))
+
+	idToken, _, err := pc.ResetPassword(ctx, "reset-token", "new password")
+	require.NoError(t, err)
+	assert.Equal(t, "reset", idToken)
+
+	idToken, _, err = pc.PasswordlessLogin(ctx, "passwordless-token")
+	require.NoError(t, err)
+	assert.Equal(t, "passwordless", idToken)
+
+	var errResp *ErrorResponse
+	_, _, err = pc.ResetPassword(ctx, "reset-token", "")
+	require.True(t, errors.As(err, &errResp))
+	assert.True(t, errResp.HasField("password"))
+
+	_, _, err = pc.PasswordlessLogin(ctx, "expired")
+	require.True(t, errors.As(err, &errResp))
+	msg, _ := errResp.Field("token")
+	assert.Equal(t, "INVALID_OR_EXPIRED", msg)
+}
@@ -0,0 +1,103 @@
+package authn
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// StaticJWKProvider is a JWKProvider serving a fixed set of keys without any
+// HTTP access. Use it with NewIDTokenVerifier in air-gapped batch jobs and tests.
+type StaticJWKProvider struct {
+	jwks jose.JSONWebKeySet
+}
+
+// NewStaticJWKProvider returns a StaticJWKProvider serving the keys in jwks.
+// Private keys are reduced to their public part.
+func NewStaticJWKProvider(jwks jose.JSONWebKeySet) (*StaticJWKProvider, error) {
+	p := &StaticJWKProvider{}
+	for _, key := range jwks.Keys {
+		if !key.IsPublic() {
+			public := key.Public()
+			if !public.Valid() {
+				return nil, fmt.Errorf("key %q has no public part", key.KeyID)
+			}
+			key = public
+		}
+		p.jwks.Keys = append(p.jwks.Keys, key)
+	}
+	return p, nil
+}
+
+// NewJWKProviderFromFile returns a StaticJWKProvider serving the keys of the
+// JWKS document at path, e.g. a copy of the AuthN server's /jwks response
+func NewJWKProviderFromFile(path string) (*StaticJWKProvider, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := jose.JSONWebKeySet{}
+	err = json.Unmarshal(data, &jwks)
+	if err != nil {
+		return nil, err
+	}
+	return NewStaticJWKProvider(jwks)
+}
+
+// NewJWKProviderFromPEM returns a StaticJWKProvider serving PEM encoded public
+// keys by their key ID. Supported are PKIX ("PUBLIC KEY") and PKCS #1
+// ("RSA PUBLIC KEY") public keys as well as certificates.
+func NewJWKProviderFromPEM(keys map[string][]byte) (*StaticJWKProvider, error) {
+	jwks := jose.JSONWebKeySet{}
+	for kid, data := range keys {
+		key, err := parsePEMPublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", kid, err)
+		}
+		jwks.Keys = append(jwks.Keys, jose.JSONWebKey{Key: key, KeyID: kid, Use: "sig"})
+	}
+	return NewStaticJWKProvider(jwks)
+}
+
+func parsePEMPublicKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %q", block.Type)
+	}
+}
+
+// Key returns the keys with the given key ID
+func (p *StaticJWKProvider) Key(kid string) ([]jose.JSONWebKey, error) {
+	return p.jwks.Key(kid), nil
+}
+
+// KeyContext works like Key. It never blocks, so ctx is ignored.
+func (p *StaticJWKProvider) KeyContext(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	return p.Key(kid)
+}
+
+// KeySet returns all keys of p
+func (p *StaticJWKProvider) KeySet(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	return &jose.JSONWebKeySet{Keys: p.jwks.Keys}, nil
+}
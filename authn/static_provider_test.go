@@ -0,0 +1,93 @@
+package authn
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticJWKProvider(t *testing.T) {
+	signing := newTestKey(t, "kid1")
+	key := signing.key
+	token := signing.sign(t, testClaims(time.Now()))
+
+	dir, err := ioutil.TempDir("", "authn-go")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	jwksPath := filepath.Join(dir, "jwks.json")
+	jwksJSON, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: key.Public(), KeyID: "kid1", Use: "sig"}}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(jwksPath, jwksJSON, 0600))
+
+	pkix, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	providers := map[string]func() (*StaticJWKProvider, error){
+		"key set": func() (*StaticJWKProvider, error) {
+			return NewStaticJWKProvider(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: key, KeyID: "kid1"}}})
+		},
+		"file": func() (*StaticJWKProvider, error) {
+			return NewJWKProviderFromFile(jwksPath)
+		},
+		"PKIX PEM": func() (*StaticJWKProvider, error) {
+			return NewJWKProviderFromPEM(map[string][]byte{
+				"kid1": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}),
+			})
+		},
+		"PKCS1 PEM": func() (*StaticJWKProvider, error) {
+			return NewJWKProviderFromPEM(map[string][]byte{
+				"kid1": pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}),
+			})
+		},
+	}
+
+	for name, newProvider := range providers {
+		t.Run(name, func(t *testing.T) {
+			provider, err := newProvider()
+			require.NoError(t, err)
+
+			keys, err := provider.Key("kid1")
+			require.NoError(t, err)
+			require.Len(t, keys, 1)
+			assert.True(t, keys[0].IsPublic())
+
+			keys, err = provider.Key("kid2")
+			require.NoError(t, err)
+			assert.Len(t, keys, 0)
+
+			jwks, err := provider.KeySet(context.Background())
+			require.NoError(t, err)
+			assert.Len(t, jwks.Keys, 1)
+
+			verifier, err := NewIDTokenVerifier("https://authn.example.com", "app.example.com", provider)
+			require.NoError(t, err)
+			claims, err := verifier.GetVerifiedClaims(token)
+			require.NoError(t, err)
+			assert.Equal(t, "42", claims.Subject)
+		})
+	}
+}
+
+func TestStaticJWKProviderErrors(t *testing.T) {
+	_, err := NewJWKProviderFromFile(filepath.Join(os.TempDir(), "authn-go-missing.json"))
+	assert.Error(t, err)
+
+	_, err = NewJWKProviderFromPEM(map[string][]byte{"kid1": []byte("not pem")})
+	assert.EqualError(t, err, `key "kid1": no PEM data found`)
+
+	_, err = NewJWKProviderFromPEM(map[string][]byte{
+		"kid1": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("secret")}),
+	})
+	assert.EqualError(t, err, `key "kid1": unsupported PEM type "EC PRIVATE KEY"`)
+}
@@ -1,54 +1,143 @@
 package authn
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/url"
 	"time"
 
+	jose "github.com/go-jose/go-jose/v3"
 	jwt "github.com/go-jose/go-jose/v3/jwt"
 )
 
-var ErrNoKey = errors.New("No keys found")
+// defaultAlgorithms are the signing algorithms accepted unless configured
+// otherwise. AuthN signs its ID tokens with RS256.
+var defaultAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
+
+// DefaultAlgorithms returns the signing algorithms accepted unless configured
+// otherwise. The returned slice is a copy, so changing it has no effect.
+func DefaultAlgorithms() []jose.SignatureAlgorithm {
+	return copyAlgorithms(defaultAlgorithms)
+}
+
+func copyAlgorithms(algorithms []jose.SignatureAlgorithm) []jose.SignatureAlgorithm {
+	return append([]jose.SignatureAlgorithm(nil), algorithms...)
+}
 
 // A JWT Claims extractor (JWTClaimsExtractor) implementation
 // which extracts claims from Authn idToken
 type idTokenVerifier struct {
-	audience  jwt.Audience
-	keychain  JWKProvider
-	issuerURL *url.URL
+	audience   jwt.Audience
+	keychain   JWKProvider
+	issuerURL  *url.URL
+	leeway     time.Duration
+	clock      Clock
+	algorithms []jose.SignatureAlgorithm
+	cache      *ClaimsCache
+	maxAuthAge time.Duration
+	policy     AudiencePolicy
+}
+
+// VerifierOption configures an idTokenVerifier
+type VerifierOption func(*idTokenVerifier)
+
+// WithLeeway sets the clock skew tolerated when validating the time based
+// claims exp, nbf and iat. Defaults to jwt.DefaultLeeway.
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(verifier *idTokenVerifier) {
+		verifier.leeway = leeway
+	}
+}
+
+// WithClock sets the source of the current time used when validating
+// the time based claims. Defaults to the system clock.
+func WithClock(clock Clock) VerifierOption {
+	return func(verifier *idTokenVerifier) {
+		verifier.clock = clock
+	}
+}
+
+// WithAllowedAlgorithms sets the signing algorithms a token may use. Tokens
+// signed with any other algorithm are rejected before their key is looked
+// up. Defaults to DefaultAlgorithms().
+func WithAllowedAlgorithms(algorithms ...jose.SignatureAlgorithm) VerifierOption {
+	algorithms = copyAlgorithms(algorithms)
+	return func(verifier *idTokenVerifier) {
+		verifier.algorithms = algorithms
+	}
+}
+
+// WithClaimsCache makes the verifier remember tokens with a verified signature
+// in cache, so that verifying them again skips the signature check. Disabled
+// by default.
+func WithClaimsCache(cache *ClaimsCache) VerifierOption {
+	return func(verifier *idTokenVerifier) {
+		verifier.cache = cache
+	}
+}
+
+// WithMaxAuthAge rejects tokens of users who authenticated more than maxAge
+// ago, or whose auth_time is unknown, with ErrAuthTooOld. Use it to require
+// a recent login for sensitive operations. Disabled by default.
+func WithMaxAuthAge(maxAge time.Duration) VerifierOption {
+	return func(verifier *idTokenVerifier) {
+		verifier.maxAuthAge = maxAge
+	}
+}
+
+// WithAudiencePolicy makes the verifier accept the audiences matched by
+// policy instead of its fixed audience.
+func WithAudiencePolicy(policy AudiencePolicy) VerifierOption {
+	return func(verifier *idTokenVerifier) {
+		verifier.policy = policy
+	}
 }
 
 // NewIDTokenVerifier creates a new idTokenVerifier object by using keychain as the JWK provider
 // Claims are verified against the values specified in config
-func NewIDTokenVerifier(issuer, audience string, keychain JWKProvider) (JWTClaimsExtractor, error) {
-	return newIDTokenVerifierWithAudiences(issuer, jwt.Audience{audience}, keychain)
+func NewIDTokenVerifier(issuer, audience string, keychain JWKProvider, opts ...VerifierOption) (JWTClaimsExtractor, error) {
+	return newIDTokenVerifierWithAudiences(issuer, jwt.Audience{audience}, keychain, opts...)
 }
 
 // newIDTokenVerifierWithAudiences creates a new idTokenVerifier object by using keychain as the JWT provider
 // Claims are verified against issuer and the set of audiences
-func newIDTokenVerifierWithAudiences(issuer string, audiences jwt.Audience, keychain JWKProvider) (*idTokenVerifier, error) {
+func newIDTokenVerifierWithAudiences(issuer string, audiences jwt.Audience, keychain JWKProvider, opts ...VerifierOption) (*idTokenVerifier, error) {
 	issuerURL, err := url.Parse(issuer)
 	if err != nil {
 		return nil, err
 	}
 
-	return &idTokenVerifier{
-		audience:  audiences,
-		keychain:  keychain,
-		issuerURL: issuerURL,
-	}, nil
+	verifier := &idTokenVerifier{
+		audience:   audiences,
+		keychain:   keychain,
+		issuerURL:  issuerURL,
+		leeway:     jwt.DefaultLeeway,
+		clock:      systemClock{},
+		algorithms: defaultAlgorithms,
+	}
+	for _, opt := range opts {
+		opt(verifier)
+	}
+	return verifier, nil
 }
 
 // Gets verified claims from an Authn idToken
 func (verifier *idTokenVerifier) GetVerifiedClaims(idToken string) (*Claims, error) {
+	return verifier.GetVerifiedClaimsContext(context.Background(), idToken)
+}
+
+// Gets verified claims from an Authn idToken. The key lookup honors
+// the cancellation and deadline of ctx
+func (verifier *idTokenVerifier) GetVerifiedClaimsContext(ctx context.Context, idToken string) (*Claims, error) {
 	var err error
 
-	claims, err := verifier.claims(idToken)
+	claims, err := verifier.cachedClaims(ctx, idToken)
 	if err != nil {
 		return nil, err
 	}
 
-	err = verifier.verify(claims)
+	err = verifier.verify(ctx, claims)
 	if err != nil {
 		return nil, err
 	}
@@ -56,45 +145,105 @@ func (verifier *idTokenVerifier) GetVerifiedClaims(idToken string) (*Claims, err
 	return claims, nil
 }
 
+// cachedClaims works like claims but consults the claims cache, if any, first
+func (verifier *idTokenVerifier) cachedClaims(ctx context.Context, idToken string) (*Claims, error) {
+	if verifier.cache == nil {
+		return verifier.claims(ctx, idToken)
+	}
+	if claims, ok := verifier.cache.get(idToken, verifier.clock.Now()); ok {
+		return claims, nil
+	}
+
+	claims, err := verifier.claims(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	verifier.cache.add(idToken, claims)
+	return claims, nil
+}
+
 // Gets claims object from an idToken using the key from keychain
 // Key from keychain is fetched using KeyID found in idToken's header
-func (verifier *idTokenVerifier) claims(idToken string) (*Claims, error) {
+func (verifier *idTokenVerifier) claims(ctx context.Context, idToken string) (*Claims, error) {
 	var err error
 
 	idJwt, err := jwt.ParseSigned(idToken)
 	if err != nil {
-		return nil, err
+		return nil, newVerificationError(ErrMalformedToken, err)
 	}
 
 	headers := idJwt.Headers
 	if len(headers) != 1 {
-		return nil, errors.New("Multi-signature JWT not supported or missing headers information")
+		return nil, newVerificationError(ErrMalformedToken, errors.New("Multi-signature JWT not supported or missing headers information"))
+	}
+	alg := jose.SignatureAlgorithm(headers[0].Algorithm)
+	if !verifier.allows(alg) {
+		return nil, newVerificationError(ErrDisallowedAlgorithm, fmt.Errorf("token uses %q", alg))
 	}
 	keyID := headers[0].KeyID
-	keys, err := verifier.keychain.Key(keyID)
+	keys, err := keyContext(ctx, verifier.keychain, keyID)
 	if err != nil {
-		return nil, err
+		return nil, newVerificationError(ErrKeyFetch, err)
 	}
 	if len(keys) == 0 {
-		return nil, ErrNoKey
+		return nil, newVerificationError(ErrUnknownKey, nil)
 	}
 	key := keys[0]
+	if key.Algorithm != "" && key.Algorithm != string(alg) {
+		return nil, newVerificationError(ErrDisallowedAlgorithm, fmt.Errorf("key %q is meant for %q but token uses %q", keyID, key.Algorithm, alg))
+	}
 
 	claims := &Claims{}
 	err = idJwt.Claims(key, claims)
 	if err != nil {
-		return nil, err
+		return nil, signatureError(err)
 	}
 
 	return claims, nil
 }
 
+// allows reports whether alg is an allowed signing algorithm
+func (verifier *idTokenVerifier) allows(alg jose.SignatureAlgorithm) bool {
+	for _, allowed := range verifier.algorithms {
+		if alg == allowed {
+			return true
+		}
+	}
+	return false
+}
+
 // Verify the claims against the configured values
-func (verifier *idTokenVerifier) verify(claims *Claims) error {
-	// Validate rest of the claims
-	return claims.Validate(jwt.Expected{
+func (verifier *idTokenVerifier) verify(ctx context.Context, claims *Claims) error {
+	expected := jwt.Expected{
 		Issuer:   verifier.issuerURL.String(),
-		Time:     time.Now(),
+		Time:     verifier.clock.Now(),
 		Audience: verifier.audience,
-	})
+	}
+	if verifier.policy != nil {
+		// checked by matchesPolicy instead
+		expected.Audience = nil
+	}
+
+	// Validate rest of the claims
+	err := claims.ValidateWithLeeway(expected, verifier.leeway)
+	if err != nil {
+		return validationError(err)
+	}
+	if verifier.policy != nil && !verifier.matchesPolicy(ctx, claims.Audience) {
+		return newVerificationError(ErrInvalidAudience, jwt.ErrInvalidAudience)
+	}
+	if verifier.maxAuthAge > 0 {
+		return checkAuthAge(claims, verifier.maxAuthAge, verifier.clock.Now(), verifier.leeway)
+	}
+	return nil
+}
+
+// matchesPolicy reports whether the audience policy accepts any of audiences
+func (verifier *idTokenVerifier) matchesPolicy(ctx context.Context, audiences jwt.Audience) bool {
+	for _, audience := range audiences {
+		if verifier.policy.MatchAudience(ctx, audience) {
+			return true
+		}
+	}
+	return false
 }
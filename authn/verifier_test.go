@@ -5,6 +5,7 @@ import (
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
@@ -84,7 +85,7 @@ func TestIDTokenVerifier(t *testing.T) {
 
 		// See: https://github.com/keratin/authn-go/issues/3
 		_, err = verifier.GetVerifiedClaims(token)
-		assert.Equal(t, jwt.ErrInvalidIssuer, err)
+		assert.Equal(t, &VerificationError{Kind: ErrInvalidIssuer, Err: jwt.ErrInvalidIssuer}, err)
 	})
 
 	t.Run("invalid formats", func(t *testing.T) {
@@ -100,7 +101,7 @@ func TestIDTokenVerifier(t *testing.T) {
 
 		for _, tc := range testCases {
 			_, err := verifier.GetVerifiedClaims(tc.token)
-			assert.Equal(t, tc.err, err)
+			assert.Equal(t, &VerificationError{Kind: ErrMalformedToken, Err: tc.err}, err)
 		}
 	})
 
@@ -118,7 +119,8 @@ func TestIDTokenVerifier(t *testing.T) {
 		require.NoError(t, err)
 
 		_, err = verifier.GetVerifiedClaims(token)
-		assert.Equal(t, ErrNoKey, err)
+		assert.Equal(t, &VerificationError{Kind: ErrUnknownKey}, err)
+		assert.True(t, errors.Is(err, ErrNoKey))
 	})
 
 	t.Run("alg=none attack", func(t *testing.T) {
@@ -128,7 +130,7 @@ func TestIDTokenVerifier(t *testing.T) {
 		token = swapHeader(token, map[string]string{"alg": "none", "type": "JWT"})
 
 		_, err = verifier.GetVerifiedClaims(token)
-		assert.Equal(t, ErrNoKey, err)
+		assert.Equal(t, &VerificationError{Kind: ErrDisallowedAlgorithm, Err: errors.New(`token uses "none"`)}, err)
 	})
 
 	t.Run("alg=hmac attack", func(t *testing.T) {
@@ -148,7 +150,44 @@ func TestIDTokenVerifier(t *testing.T) {
 		token = swapHeader(token, map[string]string{"alg": "HS256", "type": "JWT", "kid": defaultJWK.KeyID})
 
 		_, err = verifier.GetVerifiedClaims(token)
-		assert.Equal(t, jose.ErrCryptoFailure, err)
+		assert.Equal(t, &VerificationError{Kind: ErrDisallowedAlgorithm, Err: errors.New(`token uses "HS256"`)}, err)
+
+		// even if HS256 was allowed, the RSA key would not verify it
+		permissive, err := NewIDTokenVerifier(issuer, audience, jwks, WithAllowedAlgorithms(jose.RS256, jose.HS256))
+		require.NoError(t, err)
+		_, err = permissive.GetVerifiedClaims(token)
+		assert.Equal(t, &VerificationError{Kind: ErrInvalidSignature, Err: jose.ErrCryptoFailure}, err)
+	})
+
+	t.Run("defaults cannot be widened", func(t *testing.T) {
+		DefaultAlgorithms()[0] = jose.HS256
+		config := Config{}
+		config.setDefaults()
+		config.SigningAlgorithms[0] = jose.HS256
+
+		token, err := jwt.Signed(defaultSigner).Claims(defaultClaims).CompactSerialize()
+		require.NoError(t, err)
+		token = swapHeader(token, map[string]string{"alg": "HS256", "type": "JWT", "kid": defaultJWK.KeyID})
+
+		fresh, err := NewIDTokenVerifier(issuer, audience, jwks)
+		require.NoError(t, err)
+		_, err = fresh.GetVerifiedClaims(token)
+		assert.Equal(t, &VerificationError{Kind: ErrDisallowedAlgorithm, Err: errors.New(`token uses "HS256"`)}, err)
+		assert.Equal(t, []jose.SignatureAlgorithm{jose.RS256}, DefaultAlgorithms())
+	})
+
+	t.Run("key meant for another algorithm", func(t *testing.T) {
+		restricted := &mockJwkProvider{key_map: map[string]jose.JSONWebKey{
+			defaultJWK.KeyID: {Key: defaultKey.Public(), KeyID: defaultJWK.KeyID, Algorithm: string(jose.PS256)},
+		}}
+		restrictedVerifier, err := NewIDTokenVerifier(issuer, audience, restricted, WithAllowedAlgorithms(jose.RS256, jose.PS256))
+		require.NoError(t, err)
+
+		token, err := jwt.Signed(defaultSigner).Claims(defaultClaims).CompactSerialize()
+		require.NoError(t, err)
+
+		_, err = restrictedVerifier.GetVerifiedClaims(token)
+		assert.Equal(t, &VerificationError{Kind: ErrDisallowedAlgorithm, Err: errors.New(`key "defaultKey" is meant for "PS256" but token uses "RS256"`)}, err)
 	})
 
 	t.Run("wrong issuer", func(t *testing.T) {
@@ -158,7 +197,7 @@ func TestIDTokenVerifier(t *testing.T) {
 		require.NoError(t, err)
 
 		_, err = verifier.GetVerifiedClaims(token)
-		assert.Equal(t, jwt.ErrInvalidIssuer, err)
+		assert.Equal(t, &VerificationError{Kind: ErrInvalidIssuer, Err: jwt.ErrInvalidIssuer}, err)
 	})
 
 	t.Run("wrong audience", func(t *testing.T) {
@@ -168,7 +207,7 @@ func TestIDTokenVerifier(t *testing.T) {
 		require.NoError(t, err)
 
 		_, err = verifier.GetVerifiedClaims(token)
-		assert.Equal(t, jwt.ErrInvalidAudience, err)
+		assert.Equal(t, &VerificationError{Kind: ErrInvalidAudience, Err: jwt.ErrInvalidAudience}, err)
 	})
 
 	t.Run("tampered subject", func(t *testing.T) {
@@ -176,7 +215,7 @@ func TestIDTokenVerifier(t *testing.T) {
 		require.NoError(t, err)
 
 		_, err = verifier.GetVerifiedClaims(mergeClaims(token, map[string]string{"sub": "null"}))
-		assert.Equal(t, jose.ErrCryptoFailure, err)
+		assert.Equal(t, &VerificationError{Kind: ErrInvalidSignature, Err: jose.ErrCryptoFailure}, err)
 	})
 
 	t.Run("expired", func(t *testing.T) {
@@ -186,8 +225,67 @@ func TestIDTokenVerifier(t *testing.T) {
 		require.NoError(t, err)
 
 		_, err = verifier.GetVerifiedClaims(token)
-		assert.Equal(t, jwt.ErrExpired, err)
+		assert.Equal(t, &VerificationError{Kind: ErrTokenExpired, Err: jwt.ErrExpired}, err)
+		assert.True(t, errors.Is(err, ErrTokenExpired))
+		assert.True(t, errors.Is(err, jwt.ErrExpired))
 	})
+
+	t.Run("not yet valid", func(t *testing.T) {
+		testClaims := defaultClaims
+		testClaims.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
+		token, err := jwt.Signed(defaultSigner).Claims(testClaims).CompactSerialize()
+		require.NoError(t, err)
+
+		_, err = verifier.GetVerifiedClaims(token)
+		assert.Equal(t, &VerificationError{Kind: ErrTokenNotYetValid, Err: jwt.ErrNotValidYet}, err)
+	})
+
+	t.Run("key fetch failure", func(t *testing.T) {
+		token, err := jwt.Signed(defaultSigner).Claims(defaultClaims).CompactSerialize()
+		require.NoError(t, err)
+
+		failing, err := NewIDTokenVerifier(issuer, audience, newMockJwkProvider())
+		require.NoError(t, err)
+		_, err = failing.GetVerifiedClaims(swapHeader(token, map[string]string{"alg": "RS256", "kid": "kidError"}))
+		assert.True(t, errors.Is(err, ErrKeyFetch))
+		assert.EqualError(t, err, "signing keys could not be fetched: testing error")
+	})
+}
+
+func TestIDTokenVerifierClock(t *testing.T) {
+	key := newTestKey(t, "defaultKey")
+	jwks := key.provider()
+	token := key.sign(t, testClaims(newMockClock().Now()))
+
+	testCases := []struct {
+		name   string
+		offset time.Duration
+		leeway time.Duration
+		err    error
+	}{
+		{"valid", 30 * time.Minute, time.Minute, nil},
+		{"expired within leeway", time.Hour + 30*time.Second, time.Minute, nil},
+		{"expired beyond leeway", time.Hour + 2*time.Minute, time.Minute, ErrTokenExpired},
+		{"expired with larger leeway", time.Hour + 2*time.Minute, 5 * time.Minute, nil},
+		{"issued in the future within leeway", -30 * time.Second, time.Minute, nil},
+		{"issued in the future beyond leeway", -2 * time.Minute, time.Minute, ErrTokenNotYetValid},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := newMockClock()
+			now.Add(tc.offset)
+			verifier, err := NewIDTokenVerifier("https://authn.example.com", "app.example.com", jwks, WithClock(now), WithLeeway(tc.leeway))
+			require.NoError(t, err)
+
+			_, err = verifier.GetVerifiedClaims(token)
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+			}
+		})
+	}
 }
 
 func swapHeader(token string, newHeader map[string]string) string {
@@ -1,11 +1,16 @@
 module github.com/keratin/authn-go
 
-go 1.12
+go 1.18
 
 require (
 	github.com/go-jose/go-jose/v3 v3.0.1
-	github.com/google/go-cmp v0.6.0 // indirect
-	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/crypto v0.17.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )